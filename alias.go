@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// parseAlias parses "from=to" of --map.
+func parseAlias(s string) (string, string, error) {
+	from, to, ok := strings.Cut(s, "=")
+	if !ok || from == "" || to == "" {
+		return "", "", fmt.Errorf("invalid app mapping %q, want captured=installed", s)
+	}
+	return from, to, nil
+}
+
+// aliasOf returns the app that restores app, --map wins over the aliases of
+// the config. Names match regardless of case, an exact match first, then the
+// first of the sorted names so aliases differing in case resolve the same way
+// every time.
+func (psm *ProjSnapMaster) aliasOf(app string) (string, bool) {
+	for _, aliases := range []map[string]string{psm.opt.aliases, psm.conf.Aliases} {
+		if to, ok := aliases[app]; ok {
+			return to, true
+		}
+		for _, from := range slices.Sorted(maps.Keys(aliases)) {
+			if strings.EqualFold(from, app) {
+				return aliases[from], true
+			}
+		}
+	}
+	return app, false
+}
+
+// applyAliases restores every app of appSnapshots that has an alias in its
+// substitute, with the Args and windows it had. A substitute of another packer
+// type may lose state, e.g. a browser standing in for an IDE, it is warned of.
+func (psm *ProjSnapMaster) applyAliases(appSnapshots []AppSnapshot) []AppSnapshot {
+	result := make([]AppSnapshot, len(appSnapshots))
+	warned := make(map[string]bool)
+	for i, snap := range appSnapshots {
+		result[i] = snap
+		to, ok := psm.aliasOf(snap.AppName)
+		if !ok {
+			continue
+		}
+		from := snap.AppName
+		if !warned[from] && (len(snap.Args) > 0 || len(snap.Attachments) > 0) &&
+			reflect.TypeOf(psm.packerFor(snap.AppConfig)) != reflect.TypeOf(psm.GetPacker(to)) {
+			warned[from] = true
+			log.Printf("%s restores %s with another packer, its args and attachments may be lost", to, from)
+		}
+		conf := *snap.AppConfig
+		conf.AppName = to
+		// the captured identity names the app being replaced
+		conf.Identity = nil
+		result[i].AppConfig = &conf
+		if snap.WindowInfo != nil {
+			win := *snap.WindowInfo
+			win.App = to
+			result[i].WindowInfo = &win
+		}
+	}
+	return result
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"projsnap/apps"
+	"projsnap/wm"
+	"strings"
+	"testing"
+)
+
+func TestApplyAliases(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	psm := NewWorkspace(&ProjSnapOptions{aliases: map[string]string{"microsoft edge": "Google Chrome"}})
+	psm.conf = &ProjSnapConfig{Aliases: map[string]string{"Microsoft Edge": "Safari", "goland": "Google Chrome"}}
+	appSnapshots := []AppSnapshot{
+		{AppConfig: &apps.AppConfig{AppName: "Microsoft Edge", Args: []string{"https://go.dev"}}, WindowInfo: &wm.WindowInfo{App: "Microsoft Edge"}},
+		{AppConfig: &apps.AppConfig{AppName: "goland", Args: []string{"/work/api"}}},
+		{AppConfig: &apps.AppConfig{AppName: "Finder"}},
+	}
+
+	mapped := psm.applyAliases(appSnapshots)
+	if mapped[0].AppName != "Google Chrome" || mapped[0].WindowInfo.App != "Google Chrome" || mapped[0].Args[0] != "https://go.dev" {
+		t.Fatalf("--map must win over the config: %+v %+v", mapped[0].AppConfig, mapped[0].WindowInfo)
+	}
+	if appSnapshots[0].AppName != "Microsoft Edge" || appSnapshots[0].WindowInfo.App != "Microsoft Edge" {
+		t.Fatal("applyAliases must not change the snapshot it was given")
+	}
+	if mapped[2].AppConfig != appSnapshots[2].AppConfig {
+		t.Fatal("apps without alias are kept")
+	}
+
+	psm.opt.aliases = nil
+	psm.conf.Aliases = map[string]string{"GOLAND": "IntelliJ IDEA", "Goland": "Google Chrome", "goLand": "Safari"}
+	for range 20 {
+		if to, _ := psm.aliasOf("goland"); to != "IntelliJ IDEA" {
+			t.Fatalf("aliases differing in case must resolve in order, got %s", to)
+		}
+		if to, _ := psm.aliasOf("goLand"); to != "Safari" {
+			t.Fatalf("an exact match wins, got %s", to)
+		}
+	}
+	if out := buf.String(); !strings.Contains(out, "Google Chrome restores goland") || strings.Contains(out, "restores Microsoft Edge") {
+		t.Fatalf("expect a warning for goland only: %s", out)
+	}
+}
@@ -1,7 +1,10 @@
 package apps
 
 import (
+	"context"
+	"fmt"
 	"projsnap/utils"
+	"time"
 )
 
 type PackConfig []string
@@ -22,32 +25,123 @@ func NewAppConfigsWithArgs(appName string, args PackConfig) []AppConfig {
 	}}
 }
 
+// AppIdentity names an app independent of its localized display name: the
+// bundle identifier on macOS, the desktop file ID and executable on Linux.
+// PIDs are the processes of a running app, they are not saved.
+type AppIdentity struct {
+	BundleID  string `json:"bundle_id,omitempty"`
+	DesktopID string `json:"desktop_id,omitempty"`
+	ExecPath  string `json:"exec_path,omitempty"`
+	PIDs      []int  `json:"-"`
+}
+
 type AppConfig struct {
-	AppName     string     `json:"app_name"`
-	Args        PackConfig `json:"args"`
-	Attachments []string   `json:"attachments"`
+	AppName     string       `json:"app_name"`
+	Args        PackConfig   `json:"args"`
+	Attachments []string     `json:"attachments"`
+	Identity    *AppIdentity `json:"identity,omitempty"`
+}
+
+// LaunchName is the app to open for ws, the app its bundle identifier or
+// desktop file ID resolves to on this machine, or the display name for
+// snapshots without identity.
+func (ws *AppConfig) LaunchName(ctx context.Context) string {
+	if ws.Identity == nil {
+		return ws.AppName
+	}
+	id := ws.Identity.BundleID
+	if id == "" {
+		id = ws.Identity.DesktopID
+	}
+	if id == "" {
+		return ws.AppName
+	}
+	path, err := utils.AppPathOfBundle(ctx, id)
+	if err != nil {
+		return ws.AppName
+	}
+	return path
 }
 
 type AppPacker interface {
-	Pack(configDir string, appName string) ([]AppConfig, error)
-	Unpack(*AppConfig, bool) error
-	Quit(string) error
+	Pack(ctx context.Context, configDir string, appName string) ([]AppConfig, error)
+	Unpack(context.Context, *AppConfig, bool) error
+	Quit(context.Context, string) error
+}
+
+// Previewer is implemented by packers whose Pack has side effects, Preview
+// captures the same state without quitting or touching the app.
+type Previewer interface {
+	Preview(ctx context.Context, configDir string, appName string) ([]AppConfig, error)
+}
+
+// AppPatcher is implemented by packers that can add the missing Args (tabs,
+// projects, files) to a running app without restarting it, ws is the
+// snapshot entry of the app.
+type AppPatcher interface {
+	Patch(ctx context.Context, ws *AppConfig, missing []string) error
+}
+
+// OrderedPacker is implemented by packers whose pack, unpack and quit must not
+// run next to other apps, they run one by one after the concurrent ones.
+type OrderedPacker interface {
+	Ordered() bool
+}
+
+// PathChecker is implemented by packers whose Args are filesystem paths,
+// ArgPath returns the path that must exist to restore arg, "" if it is none.
+type PathChecker interface {
+	ArgPath(arg string) string
+}
+
+// WindowCloser is implemented by packers whose Quit only closes the windows
+// and leaves the app running, their quit is confirmed by no window left open.
+type WindowCloser interface {
+	ClosesWindows() bool
+}
+
+// relaunchQuitTimeout bounds the wait for a running app to quit before Unpack
+// opens it again.
+const relaunchQuitTimeout = 10 * time.Second
+
+// quitForRelaunch quits appName and waits for it to exit. An app opened while
+// the old one is still exiting races it, e.g. for the files it writes on exit.
+func quitForRelaunch(ctx context.Context, appName string) error {
+	return utils.QuitAndWait(ctx, appName, relaunchQuitTimeout)
+}
+
+// waitWindowsClosed waits up to timeout for appName to have no window left,
+// for the packers whose Quit only closes the windows.
+func waitWindowsClosed(ctx context.Context, appName string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		windows, err := utils.GetCurrenWindowsFile(ctx, appName)
+		if err == nil && len(windows) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s still has windows open after %s: %v", appName, timeout, windows)
+		}
+		if err := utils.Sleep(ctx, 200*time.Millisecond); err != nil {
+			return err
+		}
+	}
 }
 
 type NormalPacker struct {
 }
 
-func (NormalPacker) Pack(_, appName string) ([]AppConfig, error) {
+func (NormalPacker) Pack(_ context.Context, _, appName string) ([]AppConfig, error) {
 	return NewAppConfigs(appName), nil
 }
 
-func (NormalPacker) Unpack(ws *AppConfig, running bool) error {
+func (NormalPacker) Unpack(ctx context.Context, ws *AppConfig, running bool) error {
 	if !running {
-		return utils.OpenApp(ws.AppName)
+		return utils.OpenApp(ctx, ws.LaunchName(ctx))
 	}
 	return nil
 }
 
-func (NormalPacker) Quit(appName string) error {
-	return utils.GracefulQuit(appName)
+func (NormalPacker) Quit(ctx context.Context, appName string) error {
+	return utils.GracefulQuit(ctx, appName)
 }
@@ -1,6 +1,7 @@
 package apps
 
 import (
+	"context"
 	"fmt"
 	"projsnap/utils"
 	"strings"
@@ -9,7 +10,7 @@ import (
 type Browser struct {
 }
 
-func (b Browser) Pack(_, browserName string) ([]AppConfig, error) {
+func (b Browser) Pack(ctx context.Context, _, browserName string) ([]AppConfig, error) {
 	browserScript := fmt.Sprintf(`
 tell application "%s"
 	set winList to {}
@@ -24,7 +25,7 @@ tell application "%s"
 end tell`, browserName)
 
 	tabs := make([]AppConfig, 0)
-	err := utils.RunOsascriptWithSplit(browserScript, func(output string) error {
+	err := utils.RunOsascriptWithSplit(ctx, browserScript, func(output string) error {
 		windows := strings.Split(output, "\n")
 		for _, wind := range windows {
 			if wind == "" {
@@ -45,24 +46,37 @@ end tell`, browserName)
 	return tabs, err
 }
 
-func (b Browser) Unpack(ws *AppConfig, running bool) error {
+func (b Browser) Unpack(ctx context.Context, ws *AppConfig, running bool) error {
 	if running {
-		_ = b.Quit(ws.AppName)
+		if err := b.Quit(ctx, ws.AppName); err != nil {
+			return err
+		}
+		if err := waitWindowsClosed(ctx, ws.AppName, relaunchQuitTimeout); err != nil {
+			return err
+		}
 	}
 	openArgs := make([]string, 0)
 	openArgs = append(openArgs, "-n", "--args")
 	for _, tab := range ws.Args {
 		openArgs = append(openArgs, "--new-window", tab)
 	}
-	err := utils.OpenApp(ws.AppName, openArgs...)
+	err := utils.OpenApp(ctx, ws.LaunchName(ctx), openArgs...)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (b Browser) Quit(browserName string) error {
+func (b Browser) Patch(ctx context.Context, ws *AppConfig, missing []string) error {
+	return utils.OpenApp(ctx, ws.LaunchName(ctx), missing...)
+}
+
+func (b Browser) ClosesWindows() bool {
+	return true
+}
+
+func (b Browser) Quit(ctx context.Context, browserName string) error {
 	quitScript := fmt.Sprintf(` tell application "%s" to close every window`, browserName)
-	_, err := utils.RunOsascript(quitScript)
+	_, err := utils.RunOsascript(ctx, quitScript)
 	return err
 }
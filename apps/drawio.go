@@ -1,9 +1,11 @@
 package apps
 
 import (
+	"context"
 	"path/filepath"
 	"projsnap/utils"
 	"strings"
+	"time"
 )
 
 var (
@@ -11,11 +13,13 @@ var (
 	drawIOAppName    = "draw.io"
 )
 
+const drawIOQuitTimeout = 10 * time.Second
+
 type DrawIO struct {
 }
 
-func getDrawIOOpenFiles() ([]string, error) {
-	titles, err := utils.GetCurrenWindowsFile(drawIOAppName)
+func getDrawIOOpenFiles(ctx context.Context) ([]string, error) {
+	titles, err := utils.GetCurrenWindowsFile(ctx, drawIOAppName)
 	if err != nil {
 		return nil, err
 	}
@@ -30,12 +34,13 @@ func getDrawIOOpenFiles() ([]string, error) {
 	return fileNames, nil
 }
 
-func (d DrawIO) Pack(_, appName string) ([]AppConfig, error) {
-	fileNames, err := getDrawIOOpenFiles()
+func (d DrawIO) Pack(ctx context.Context, _, appName string) ([]AppConfig, error) {
+	fileNames, err := getDrawIOOpenFiles(ctx)
 	if err != nil {
 		return nil, err
 	}
-	if err := utils.GracefulQuit("draw.io"); err != nil {
+	// draw.io flushes its recent files on exit
+	if err := utils.QuitAndWait(ctx, "draw.io", drawIOQuitTimeout); err != nil {
 		return nil, err
 	}
 
@@ -54,13 +59,44 @@ func (d DrawIO) Pack(_, appName string) ([]AppConfig, error) {
 	return NewAppConfigsWithArgs(appName, filePaths), nil
 }
 
-func (d DrawIO) Unpack(ws *AppConfig, running bool) error {
+// Preview can not quit draw.io to flush its recent files, it resolves what is
+// already on disk and falls back to the bare file names.
+func (d DrawIO) Preview(ctx context.Context, _, appName string) ([]AppConfig, error) {
+	fileNames, err := getDrawIOOpenFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	recentFiles, err := utils.ReadRecentFiles(drawIOConfigPath)
+	if err != nil {
+		return NewAppConfigsWithArgs(appName, fileNames), nil
+	}
+	filePaths := make([]string, 0)
+	for _, fileName := range fileNames {
+		filePath := fileName
+		for _, recentFile := range recentFiles {
+			if strings.HasSuffix(recentFile, fileName) {
+				filePath = recentFile
+				break
+			}
+		}
+		filePaths = append(filePaths, filePath)
+	}
+	return NewAppConfigsWithArgs(appName, filePaths), nil
+}
+
+func (d DrawIO) Unpack(ctx context.Context, ws *AppConfig, running bool) error {
 	if running {
-		_ = d.Quit(ws.AppName)
+		if err := quitForRelaunch(ctx, ws.AppName); err != nil {
+			return err
+		}
 	}
+	return d.openFiles(ctx, ws, ws.Args)
+}
+
+func (d DrawIO) openFiles(ctx context.Context, ws *AppConfig, files []string) error {
 	taskMap := make(map[string]bool)
-	return utils.OpenMultiAppByRetry(func() ([]string, error) {
-		doneArgs, err := getDrawIOOpenFiles()
+	return utils.OpenMultiAppByRetry(ctx, func(ctx context.Context) ([]string, error) {
+		doneArgs, err := getDrawIOOpenFiles(ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -69,15 +105,27 @@ func (d DrawIO) Unpack(ws *AppConfig, running bool) error {
 		}
 
 		failedArgs := make([]string, 0)
-		for _, arg := range ws.Args {
+		for _, arg := range files {
 			if _, ok := taskMap[filepath.Base(arg)]; !ok {
 				failedArgs = append(failedArgs, arg)
 			}
 		}
 		return failedArgs, nil
-	}, ws.AppName, ws.Args...)
+	}, ws.LaunchName(ctx), ws.AppName, files...)
+}
+
+func (d DrawIO) Patch(ctx context.Context, ws *AppConfig, missing []string) error {
+	return d.openFiles(ctx, ws, missing)
+}
+
+// ArgPath skips the bare file names a Preview could not resolve.
+func (d DrawIO) ArgPath(arg string) string {
+	if !filepath.IsAbs(arg) {
+		return ""
+	}
+	return arg
 }
 
-func (d DrawIO) Quit(appName string) error {
-	return utils.GracefulQuit(appName)
+func (d DrawIO) Quit(ctx context.Context, appName string) error {
+	return utils.GracefulQuit(ctx, appName)
 }
@@ -1,13 +1,14 @@
 package apps
 
 import (
+	"context"
 	"projsnap/utils"
 )
 
 type Finder struct {
 }
 
-func (f Finder) Pack(_, appName string) ([]AppConfig, error) {
+func (f Finder) Pack(ctx context.Context, _, appName string) ([]AppConfig, error) {
 	script := `
 	tell application "Finder"
 		set window_list to every Finder window
@@ -19,20 +20,30 @@ func (f Finder) Pack(_, appName string) ([]AppConfig, error) {
 		return paths
 	end tell`
 
-	tmp, err := utils.RunOsascript(script)
+	tmp, err := utils.RunOsascript(ctx, script)
 	return NewAppConfigsWithArgs(appName, tmp), err
 }
 
-func (f Finder) Unpack(ws *AppConfig, running bool) error {
+func (f Finder) Unpack(ctx context.Context, ws *AppConfig, running bool) error {
 	if len(ws.Args) == 0 {
 		return nil
 	}
 	if running {
-		_ = f.Quit(ws.AppName)
+		if err := quitForRelaunch(ctx, ws.AppName); err != nil {
+			return err
+		}
 	}
-	return utils.OpenApp(ws.AppName, ws.Args...)
+	return utils.OpenApp(ctx, ws.LaunchName(ctx), ws.Args...)
 }
 
-func (f Finder) Quit(appName string) error {
-	return utils.GracefulQuit(appName)
+func (f Finder) Patch(ctx context.Context, ws *AppConfig, missing []string) error {
+	return utils.OpenApp(ctx, ws.LaunchName(ctx), missing...)
+}
+
+func (f Finder) ArgPath(arg string) string {
+	return arg
+}
+
+func (f Finder) Quit(ctx context.Context, appName string) error {
+	return utils.GracefulQuit(ctx, appName)
 }
@@ -1,17 +1,19 @@
 package apps
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"projsnap/utils"
+	"slices"
 	"time"
 )
 
 type Iterm2 struct {
 }
 
-func (Iterm2) Pack(_, appName string) ([]AppConfig, error) {
+func (Iterm2) Pack(ctx context.Context, _, appName string) ([]AppConfig, error) {
 	iterm2File := filepath.Join(os.TempDir(), ".iterm2.txt")
 	_ = os.Remove(iterm2File)
 	script := fmt.Sprintf(`tell application "iTerm"
@@ -25,22 +27,60 @@ func (Iterm2) Pack(_, appName string) ([]AppConfig, error) {
   end repeat
   return output
 end tell`, iterm2File)
-	_, err := utils.RunOsascript(script)
+	_, err := utils.RunOsascript(ctx, script)
 	if err != nil {
 		// only one tab occur error, ignore it.
 		return nil, nil
 		//return nil, err
 	}
-	time.Sleep(1 * time.Second) // wait for iterm2 to write
 	defer os.Remove(iterm2File)
+	// wait for iterm2 to write
+	if err := utils.Sleep(ctx, 1*time.Second); err != nil {
+		return nil, err
+	}
 	result, err := utils.ReadFileToStringList(iterm2File)
 	return NewAppConfigsWithArgs(appName, result), err
 }
 
-func (Iterm2) Unpack(ws *AppConfig, _ bool) error {
-	return utils.OpenApp("iterm", ws.Args...)
+// Preview reads the working directory of every session from iTerm2 instead of
+// typing `pwd` into them.
+func (Iterm2) Preview(ctx context.Context, _, appName string) ([]AppConfig, error) {
+	script := `tell application "iTerm"
+  set paths to {}
+  repeat with w in windows
+    repeat with t in tabs of w
+      repeat with s in sessions of t
+        tell s to set end of paths to (variable named "session.path")
+      end repeat
+    end repeat
+  end repeat
+  return paths
+end tell`
+	result, err := utils.RunOsascript(ctx, script)
+	if err != nil {
+		return nil, err
+	}
+	return NewAppConfigsWithArgs(appName, slices.DeleteFunc(result, func(s string) bool { return s == "" })), nil
+}
+
+func (Iterm2) Unpack(ctx context.Context, ws *AppConfig, _ bool) error {
+	return utils.OpenApp(ctx, ws.LaunchName(ctx), ws.Args...)
+}
+
+func (Iterm2) Patch(ctx context.Context, ws *AppConfig, missing []string) error {
+	return utils.OpenApp(ctx, ws.LaunchName(ctx), missing...)
+}
+
+// Ordered keeps iTerm2 out of the worker pool, it usually hosts projsnap itself
+// and has to be quit last.
+func (Iterm2) Ordered() bool {
+	return true
+}
+
+func (Iterm2) ArgPath(arg string) string {
+	return arg
 }
 
-func (Iterm2) Quit(appName string) error {
-	return utils.GracefulQuit(appName)
+func (Iterm2) Quit(ctx context.Context, appName string) error {
+	return utils.GracefulQuit(ctx, appName)
 }
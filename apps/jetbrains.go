@@ -1,6 +1,7 @@
 package apps
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -11,8 +12,8 @@ import (
 type JetBrains struct {
 }
 
-func getJetBrainsIOOpenFiles(appName string) ([]string, error) {
-	titles, err := utils.GetCurrenWindowsFile(appName)
+func getJetBrainsIOOpenFiles(ctx context.Context, appName string) ([]string, error) {
+	titles, err := utils.GetCurrenWindowsFile(ctx, appName)
 	if err != nil {
 		return nil, err
 	}
@@ -32,8 +33,8 @@ func ReadRecentProjectFile(ideName string) ([]byte, error) {
 	return os.ReadFile(matched[0])
 }
 
-func (j JetBrains) Pack(_, ideName string) ([]AppConfig, error) {
-	projectNames, err := getJetBrainsIOOpenFiles(ideName)
+func (j JetBrains) Pack(ctx context.Context, _, ideName string) ([]AppConfig, error) {
+	projectNames, err := getJetBrainsIOOpenFiles(ctx, ideName)
 	if err != nil {
 		return nil, fmt.Errorf("getJetBrainsIOOpenFiles occur fail, err: %v\n", err)
 	}
@@ -63,13 +64,23 @@ func (j JetBrains) Pack(_, ideName string) ([]AppConfig, error) {
 	return NewAppConfigsWithArgs(ideName, openProjects), nil
 }
 
-func (j JetBrains) Unpack(ws *AppConfig, running bool) error {
+func (j JetBrains) Unpack(ctx context.Context, ws *AppConfig, running bool) error {
 	if running {
-		_ = j.Quit(ws.AppName)
+		if err := quitForRelaunch(ctx, ws.AppName); err != nil {
+			return err
+		}
 	}
-	return utils.OpenMultiApp(ws.AppName, ws.Args...)
+	return utils.OpenMultiApp(ctx, ws.LaunchName(ctx), ws.AppName, ws.Args...)
 }
 
-func (j JetBrains) Quit(appName string) error {
-	return utils.GracefulQuit(appName)
+func (j JetBrains) Patch(ctx context.Context, ws *AppConfig, missing []string) error {
+	return utils.OpenMultiApp(ctx, ws.LaunchName(ctx), ws.AppName, missing...)
+}
+
+func (j JetBrains) ArgPath(arg string) string {
+	return arg
+}
+
+func (j JetBrains) Quit(ctx context.Context, appName string) error {
+	return utils.GracefulQuit(ctx, appName)
 }
@@ -1,6 +1,7 @@
 package apps
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"os"
@@ -25,7 +26,7 @@ type Vault struct {
 type Obsidian struct {
 }
 
-func (o Obsidian) Pack(_, appName string) ([]AppConfig, error) {
+func (o Obsidian) Pack(_ context.Context, _, appName string) ([]AppConfig, error) {
 	fd, err := os.Open(obsidianConfigPath)
 	if err != nil {
 		return nil, err
@@ -53,16 +54,24 @@ func (o Obsidian) Pack(_, appName string) ([]AppConfig, error) {
 	return config, nil
 }
 
-func (o Obsidian) Unpack(ws *AppConfig, running bool) error {
+func (o Obsidian) Unpack(ctx context.Context, ws *AppConfig, running bool) error {
 	if running {
-		_ = o.Quit(ws.AppName)
+		if err := quitForRelaunch(ctx, ws.AppName); err != nil {
+			return err
+		}
 	}
 	if err := utils.RecoverBakFile(ws.Args[0], ws.Attachments[0]); err != nil {
 		return err
 	}
-	return utils.OpenApp(ws.AppName)
+	return utils.OpenApp(ctx, ws.LaunchName(ctx))
+}
+
+// ArgPath is the .obsidian folder of the vault, Unpack writes workspace.json
+// back into it.
+func (o Obsidian) ArgPath(arg string) string {
+	return filepath.Dir(arg)
 }
 
-func (o Obsidian) Quit(appName string) error {
-	return utils.GracefulQuit(appName)
+func (o Obsidian) Quit(ctx context.Context, appName string) error {
+	return utils.GracefulQuit(ctx, appName)
 }
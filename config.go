@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	configFileName     = "config.json"
+	defaultUndoLevels  = 5
+	defaultAppTimeout  = 2 * time.Minute
+	defaultWorkers     = 4
+	defaultHookTimeout = 30 * time.Second
+	defaultQuitTimeout = 10 * time.Second
+
+	defaultReadyTimeout     = 30 * time.Second
+	defaultReadyInterval    = 250 * time.Millisecond
+	defaultReadyMaxInterval = 2 * time.Second
+)
+
+// Duration is a time.Duration written as "30s" or "2m" in config.json.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// ProjSnapConfig is the per-profile config.json, a missing file means defaults.
+type ProjSnapConfig struct {
+	UndoLevels   int                  `json:"undo_levels"`
+	FailOn       string               `json:"fail_on"`
+	CriticalApps []string             `json:"critical_apps"`
+	AppTimeout   Duration             `json:"app_timeout"`
+	AppTimeouts  map[string]Duration  `json:"app_timeouts"`
+	Workers      int                  `json:"workers"`
+	Apps         map[string]AppPolicy `json:"apps"`
+	Hooks        map[string][]Hook    `json:"hooks"`
+	HookTimeout  Duration             `json:"hook_timeout"`
+	Remap        []RemapRule          `json:"remap"`
+	// captured app name to the installed app restoring it
+	Aliases map[string]string `json:"aliases"`
+	// how long an app gets to quit before its quit strategy escalates
+	QuitTimeout Duration `json:"quit_timeout"`
+	// yabai space the windows of hidden apps are parked on, 0 leaves them
+	ScratchSpace int `json:"scratch_space"`
+	// window manager backend restoring the windows: yabai, i3 (sway too),
+	// hyprland, x11 or none, "" picks yabai on macOS and none elsewhere
+	WindowManager string `json:"window_manager"`
+	// windows are restored once their app is up, polling from ready_interval
+	// doubling up to ready_max_interval, for at most ready_timeout
+	ReadyTimeout     Duration `json:"ready_timeout"`
+	ReadyInterval    Duration `json:"ready_interval"`
+	ReadyMaxInterval Duration `json:"ready_max_interval"`
+}
+
+// timeoutOf returns how long a single pack, unpack or quit of app may take.
+func (conf *ProjSnapConfig) timeoutOf(app string) time.Duration {
+	for name, timeout := range conf.AppTimeouts {
+		if strings.EqualFold(name, app) {
+			return time.Duration(timeout)
+		}
+	}
+	return time.Duration(conf.AppTimeout)
+}
+
+func loadConfig(configDir string) (*ProjSnapConfig, error) {
+	conf := &ProjSnapConfig{
+		UndoLevels:  defaultUndoLevels,
+		FailOn:      failOnAny,
+		AppTimeout:  Duration(defaultAppTimeout),
+		Workers:     defaultWorkers,
+		HookTimeout: Duration(defaultHookTimeout),
+		QuitTimeout: Duration(defaultQuitTimeout),
+
+		ReadyTimeout:     Duration(defaultReadyTimeout),
+		ReadyInterval:    Duration(defaultReadyInterval),
+		ReadyMaxInterval: Duration(defaultReadyMaxInterval),
+	}
+	data, err := os.ReadFile(filepath.Join(configDir, configFileName))
+	if os.IsNotExist(err) {
+		return conf, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, conf); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	conf, err := loadConfig(dir)
+	if err != nil || conf.UndoLevels != defaultUndoLevels || conf.timeoutOf("goland") != defaultAppTimeout {
+		t.Fatalf("unexpected defaults: %+v %v", conf, err)
+	}
+
+	data := `{"app_timeout": "30s", "app_timeouts": {"GoLand": "5m"}}`
+	if err := os.WriteFile(filepath.Join(dir, configFileName), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conf, err = loadConfig(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.timeoutOf("goland") != 5*time.Minute || conf.timeoutOf("Finder") != 30*time.Second {
+		t.Fatalf("unexpected timeouts: %v %v", conf.timeoutOf("goland"), conf.timeoutOf("Finder"))
+	}
+}
+
+func TestWindowManagerConfig(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, configFileName), []byte(`{"window_manager": "none"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	psm := NewWorkspace(&ProjSnapOptions{configDir: dir})
+	if err := psm.Open(); err != nil {
+		t.Fatal(err)
+	}
+	_ = psm.Close()
+	if name := psm.wm.Backend().Name(); name != "none" {
+		t.Fatalf("backend: %s", name)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, configFileName), []byte(`{"window_manager": "kwin"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	psm = NewWorkspace(&ProjSnapOptions{configDir: dir})
+	defer psm.Close()
+	if err := psm.Open(); err == nil {
+		t.Fatal("expect an error for an unknown window manager")
+	}
+}
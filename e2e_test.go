@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"projsnap/utils"
+	"projsnap/wm"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// desktopWindows lists the windows of desktop as a window manager sees them.
+func desktopWindows(desktop *utils.FakeDesktop) func() []wm.WindowInfo {
+	return func() []wm.WindowInfo {
+		windows := make([]wm.WindowInfo, 0)
+		for _, win := range desktop.Windows() {
+			windows = append(windows, wm.WindowInfo{App: win.App, Title: win.Title, WindowID: win.ID, Pid: win.PID})
+		}
+		return windows
+	}
+}
+
+// windowOf returns the window of app titled title.
+func windowOf(windows *wm.Fake, app, title string) (wm.WindowInfo, bool) {
+	all, _ := windows.QueryWindows(context.Background())
+	for _, win := range all {
+		if win.App == app && win.Title == title {
+			return win, true
+		}
+	}
+	return wm.WindowInfo{}, false
+}
+
+func newE2EWorkspace(t *testing.T) (*ProjSnapMaster, *utils.FakeDesktop, *wm.Fake) {
+	desktop := utils.NewFakeDesktop()
+	for _, app := range []utils.FakeApp{
+		{Name: "Slack", BundleID: "com.tinyspeck.slackmacgap", Windows: func([]string) []string { return []string{"general"} }},
+		{Name: "Notes", BundleID: "com.apple.Notes", Windows: func([]string) []string { return []string{"todo"} }},
+		{Name: "Mail", BundleID: "com.apple.mail"},
+	} {
+		desktop.Install(app)
+	}
+	prev := utils.SetPlatform(desktop)
+	t.Cleanup(func() { utils.SetPlatform(prev) })
+
+	psm := NewWorkspace(&ProjSnapOptions{configDir: t.TempDir()})
+	if err := psm.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = psm.Close() })
+	psm.conf.ReadyInterval = Duration(10 * time.Millisecond)
+	psm.conf.ReadyTimeout = Duration(time.Second)
+	windows := wm.NewFake(3)
+	windows.Source = desktopWindows(desktop)
+	psm.wm = wm.NewManager(windows)
+	return psm, desktop, windows
+}
+
+// saveWork takes snapshot "work" of Slack and Notes, Slack on space 2.
+func saveWork(t *testing.T, psm *ProjSnapMaster, desktop *utils.FakeDesktop, windows *wm.Fake) {
+	for _, app := range []string{"Slack", "Notes"} {
+		if err := desktop.Start(app, map[string]string{"Slack": "general", "Notes": "todo"}[app]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	slack, _ := windowOf(windows, "Slack", "general")
+	_ = windows.MoveToSpace(context.Background(), slack.WindowID, 2)
+	_ = windows.SetFrame(context.Background(), slack.WindowID, wm.Rect{X: 100, Y: 50, W: 800, H: 600})
+	saved, err := psm.SaveSnapshot(context.Background(), "work")
+	if err != nil || !saved {
+		t.Fatalf("save: %v %v", saved, err)
+	}
+}
+
+func quitAll(t *testing.T, desktop *utils.FakeDesktop) {
+	for _, app := range desktop.Running() {
+		if err := desktop.Quit(context.Background(), app); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func checkReport(t *testing.T, report *RestoreReport, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range report.Results {
+		if result.Err != nil {
+			t.Fatalf("%s %s failed: %v", result.Kind, result.App, result.Err)
+		}
+	}
+}
+
+func TestE2ESaveSnapshot(t *testing.T) {
+	psm, desktop, windows := newE2EWorkspace(t)
+	saveWork(t, psm, desktop, windows)
+
+	appSnapshots, err := psm.loadSnapshot("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(appSnapshots) != 2 || appSnapshots[0].AppName != "Notes" || appSnapshots[1].AppName != "Slack" {
+		t.Fatalf("unexpected snapshot: %+v", appSnapshots)
+	}
+	slack := appSnapshots[1]
+	if slack.Identity == nil || slack.Identity.BundleID != "com.tinyspeck.slackmacgap" {
+		t.Fatalf("identity not captured: %+v", slack.Identity)
+	}
+	if slack.WindowInfo == nil || slack.WindowInfo.SpaceID != 2 || slack.WindowInfo.Frame.W != 800 {
+		t.Fatalf("window not captured: %+v", slack.WindowInfo)
+	}
+}
+
+func TestE2ESwitchSnapshot(t *testing.T) {
+	psm, desktop, windows := newE2EWorkspace(t)
+	saveWork(t, psm, desktop, windows)
+	quitAll(t, desktop)
+	if err := desktop.Start("Mail", "inbox"); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := psm.SwitchSnapshot(context.Background(), "work")
+	checkReport(t, report, err)
+	running := desktop.Running()
+	slices.Sort(running)
+	if !slices.Equal(running, []string{"Notes", "Slack"}) {
+		t.Fatalf("running after switch: %v", running)
+	}
+	slack, ok := windowOf(windows, "Slack", "general")
+	if !ok || slack.SpaceID != 2 || slack.Frame != (wm.Rect{X: 100, Y: 50, W: 800, H: 600}) {
+		t.Fatalf("slack window not restored: %v %+v", ok, slack)
+	}
+	if calls := strings.Join(desktop.Calls(), "\n"); !strings.Contains(calls, "quit Mail") {
+		t.Fatalf("Mail not quit: %s", calls)
+	}
+}
+
+func TestE2ERestoreSnapshot(t *testing.T) {
+	psm, desktop, windows := newE2EWorkspace(t)
+	saveWork(t, psm, desktop, windows)
+	quitAll(t, desktop)
+	if err := desktop.Start("Mail", "inbox"); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := psm.RestoreSnapshot(context.Background(), "work")
+	checkReport(t, report, err)
+	running := desktop.Running()
+	slices.Sort(running)
+	if !slices.Equal(running, []string{"Mail", "Notes", "Slack"}) {
+		t.Fatalf("restore must open the snapshot and keep the rest: %v", running)
+	}
+	if slack, ok := windowOf(windows, "Slack", "general"); !ok || slack.SpaceID != 2 {
+		t.Fatalf("slack window not on space 2: %v %+v", ok, slack)
+	}
+}
+
+// TestE2ERetrySkipInvalid continues a restore whose Slack window lost its
+// space since, the window step is skipped instead of restored.
+func TestE2ERetrySkipInvalid(t *testing.T) {
+	for _, action := range []string{"retry", "resume"} {
+		t.Run(action, func(t *testing.T) {
+			psm, desktop, windows := newE2EWorkspace(t)
+			saveWork(t, psm, desktop, windows)
+			quitAll(t, desktop)
+			appSnapshots, err := psm.loadRestoreSnapshot("work")
+			if err != nil {
+				t.Fatal(err)
+			}
+			// interrupted before any step ran
+			j := psm.newJournal(actionRestore, "work", appSnapshots, nil)
+			if err := psm.saveJournal(j); err != nil {
+				t.Fatal(err)
+			}
+
+			oneSpace := wm.NewFake(1)
+			oneSpace.Source = desktopWindows(desktop)
+			psm.wm = wm.NewManager(oneSpace)
+			psm.opt.skipInvalid = true
+			continueJournal := map[string]func(context.Context) (*RestoreReport, error){"retry": psm.Retry, "resume": psm.Resume}[action]
+			report, err := continueJournal(context.Background())
+			checkReport(t, report, err)
+
+			if j, err = psm.LastJournal(); err != nil {
+				t.Fatal(err)
+			}
+			for _, step := range j.Windows {
+				if (step.App == "Slack") != (step.Status == stepSkipped) {
+					t.Fatalf("window steps: %+v", j.Windows)
+				}
+			}
+			running := desktop.Running()
+			slices.Sort(running)
+			if !slices.Equal(running, []string{"Notes", "Slack"}) {
+				t.Fatalf("running after %s: %v", action, running)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	EventPhase          = "phase"
+	EventPackStarted    = "pack_started"
+	EventPackFinished   = "pack_finished"
+	EventAppOpening     = "app_opening"
+	EventAppOpened      = "app_opened"
+	EventAppWaiting     = "app_waiting"
+	EventAppQuit        = "app_quit"
+	EventWindowRestored = "window_restored"
+	EventError          = "error"
+)
+
+const (
+	phaseCapture = "capture"
+	phaseUndo    = "undo"
+	phaseOpen    = "open"
+	phaseQuit    = "quit"
+	phaseWindows = "windows"
+	phaseDone    = "done"
+)
+
+// Event is one step of the progress of a take, switch or restore. Done and
+// Total count the steps of the same kind, Status is the journal status of the
+// step, or for app_opening what a switch does with the app. Delay is how long
+// app_waiting waits after the app was launched.
+type Event struct {
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action,omitempty"`
+	Snapshot string    `json:"snapshot,omitempty"`
+	Phase    string    `json:"phase,omitempty"`
+	App      string    `json:"app,omitempty"`
+	Args     []string  `json:"args,omitempty"`
+	Status   string    `json:"status,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Done     int       `json:"done,omitempty"`
+	Total    int       `json:"total,omitempty"`
+	Delay    Duration  `json:"delay,omitempty"`
+}
+
+// Observer is told every event of the ProjSnapMaster it subscribed to. Events
+// are delivered one at a time, from the goroutine of the step, so Notify must
+// not block.
+type Observer interface {
+	Notify(Event)
+}
+
+type ObserverFunc func(Event)
+
+func (f ObserverFunc) Notify(e Event) {
+	f(e)
+}
+
+// Subscribe adds o to the observers, the returned func removes it again.
+func (psm *ProjSnapMaster) Subscribe(o Observer) func() {
+	psm.obsMu.Lock()
+	defer psm.obsMu.Unlock()
+	if psm.observers == nil {
+		psm.observers = make(map[int]Observer)
+	}
+	psm.obsSeq++
+	id := psm.obsSeq
+	psm.observers[id] = o
+	return func() {
+		psm.obsMu.Lock()
+		defer psm.obsMu.Unlock()
+		delete(psm.observers, id)
+	}
+}
+
+// emit delivers e to the observers subscribed when it is emitted. Notify is
+// called without obsMu held, an observer may subscribe or unsubscribe in it;
+// notifyMu keeps the events one at a time.
+func (psm *ProjSnapMaster) emit(e Event) {
+	psm.obsMu.Lock()
+	ids := slices.Sorted(maps.Keys(psm.observers))
+	observers := make([]Observer, 0, len(ids))
+	for _, id := range ids {
+		observers = append(observers, psm.observers[id])
+	}
+	psm.obsMu.Unlock()
+	if len(observers) == 0 {
+		return
+	}
+	e.Time = time.Now()
+	if e.Action == "" {
+		e.Action, e.Snapshot = psm.run.action, psm.run.snapshot
+	}
+	psm.notifyMu.Lock()
+	defer psm.notifyMu.Unlock()
+	for _, o := range observers {
+		o.Notify(e)
+	}
+}
+
+func (psm *ProjSnapMaster) emitPhase(phase string) {
+	psm.emit(Event{Type: EventPhase, Phase: phase})
+}
+
+func (psm *ProjSnapMaster) emitError(app string, err error) {
+	psm.emit(Event{Type: EventError, App: app, Error: err.Error()})
+}
+
+// counter counts finished steps running concurrently.
+type counter struct {
+	done  atomic.Int64
+	total int
+}
+
+func (c *counter) next() (int, int) {
+	return int(c.done.Add(1)), c.total
+}
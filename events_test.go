@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"github.com/boltdb/bolt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMarkStepEvents(t *testing.T) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "projsnap.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_ = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(journalBucketName)
+		return err
+	})
+	psm := &ProjSnapMaster{db: db, run: hookRun{action: actionSwitch, snapshot: "proj"}}
+	j := &RestoreJournal{Apps: []JournalStep{
+		{App: "Slack", Status: stepPending},
+		{App: "goland", Status: stepPending},
+	}}
+
+	events := make([]Event, 0)
+	unsubscribe := psm.Subscribe(ObserverFunc(func(e Event) { events = append(events, e) }))
+	psm.markStep(j, kindApp, &j.Apps[1], stepOpened, nil)
+	unsubscribe()
+	psm.markStep(j, kindApp, &j.Apps[0], stepOpened, nil)
+
+	if len(events) != 1 {
+		t.Fatalf("expect 1 event before unsubscribe, got %v", events)
+	}
+	e := events[0]
+	if e.Type != EventAppOpened || e.App != "goland" || e.Done != 1 || e.Total != 2 || e.Snapshot != "proj" {
+		t.Fatalf("unexpected event: %+v", e)
+	}
+}
+
+func TestSubscribeInNotify(t *testing.T) {
+	psm := &ProjSnapMaster{}
+	var once, second []Event
+	var unsubscribe func()
+	unsubscribe = psm.Subscribe(ObserverFunc(func(e Event) {
+		once = append(once, e)
+		unsubscribe()
+		psm.Subscribe(ObserverFunc(func(e Event) { second = append(second, e) }))
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		psm.emitPhase(phaseOpen)
+		psm.emitPhase(phaseQuit)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscribing in Notify deadlocked")
+	}
+	if len(once) != 1 || len(second) != 1 || second[0].Phase != phaseQuit {
+		t.Fatalf("events: %+v %+v", once, second)
+	}
+}
+
+func TestTextProgress(t *testing.T) {
+	var buf bytes.Buffer
+	o := textProgress(&buf)
+	o.Notify(Event{Type: EventPhase, Action: actionSwitch, Snapshot: "proj", Phase: phaseOpen})
+	o.Notify(Event{Type: EventAppOpening, App: "Slack", Status: switchSkip})
+	o.Notify(Event{Type: EventAppOpened, App: "Slack", Status: stepFailed, Error: "boom", Done: 1, Total: 3})
+	o.Notify(Event{Type: EventAppOpening, App: "goland", Status: switchUnhide})
+	o.Notify(Event{Type: EventAppWaiting, App: "goland", Delay: Duration(2 * time.Second)})
+	want := "== switch proj: open\nSkipping Slack\n[1/3] Slack failed: boom\nUnhiding goland\nWaiting 2s after launching goland\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
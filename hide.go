@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"log"
+	"projsnap/utils"
+	"strconv"
+	"time"
+)
+
+// hiddenBucketName records the apps a switch hid instead of quitting, keyed by
+// app name, so a later switch unhides them instead of relaunching.
+var hiddenBucketName = []byte("hidden")
+
+// hidesApp reports whether app is hidden instead of quit, by --hide or by its
+// lifecycle policy.
+func (psm *ProjSnapMaster) hidesApp(hide bool, app string) bool {
+	return hide || psm.policyOf(app).Hide
+}
+
+// hideApp parks the windows of app on the scratch space and hides it.
+func (psm *ProjSnapMaster) hideApp(ctx context.Context, app string) error {
+	ctx, cancel := psm.appContext(ctx, app)
+	defer cancel()
+	if psm.conf.ScratchSpace > 0 {
+		if err := psm.wm.ParkWindows(ctx, app, psm.conf.ScratchSpace); err != nil {
+			log.Printf("park windows of %s on space %d fail, err: %v", app, psm.conf.ScratchSpace, err)
+		}
+	}
+	if err := utils.HideApp(ctx, app); err != nil {
+		return err
+	}
+	return psm.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(hiddenBucketName).Put([]byte(app), []byte(strconv.FormatInt(time.Now().Unix(), 10)))
+	})
+}
+
+func (psm *ProjSnapMaster) hiddenApps() map[string]struct{} {
+	hidden := make(map[string]struct{})
+	_ = psm.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(hiddenBucketName).ForEach(func(k, v []byte) error {
+			hidden[string(k)] = struct{}{}
+			return nil
+		})
+	})
+	return hidden
+}
+
+func (psm *ProjSnapMaster) forgetHidden(app string) {
+	if err := psm.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(hiddenBucketName).Delete([]byte(app))
+	}); err != nil {
+		log.Printf("forget hidden %s fail, err: %v", app, err)
+	}
+}
+
+// unhideApps shows again the apps of appNames a switch has hidden, their
+// windows come back from the scratch space with the window restore.
+func (psm *ProjSnapMaster) unhideApps(ctx context.Context, appNames []string) {
+	hidden := psm.hiddenApps()
+	for _, app := range appNames {
+		if _, ok := hidden[app]; !ok {
+			continue
+		}
+		psm.emit(Event{Type: EventAppOpening, App: app, Status: switchUnhide})
+		if err := utils.UnhideApp(ctx, app); err != nil {
+			psm.emitError(app, fmt.Errorf("unhide fail: %w", err))
+			continue
+		}
+		psm.forgetHidden(app)
+	}
+}
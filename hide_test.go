@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"github.com/boltdb/bolt"
+	"path/filepath"
+	"projsnap/utils"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHiddenApps(t *testing.T) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "projsnap.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	psm := &ProjSnapMaster{db: db, conf: &ProjSnapConfig{Apps: map[string]AppPolicy{
+		"goland": {Hide: true},
+	}}}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(hiddenBucketName)
+		if err != nil {
+			return err
+		}
+		_ = b.Put([]byte("goland"), []byte("1"))
+		return b.Put([]byte("Slack"), []byte("1"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	psm.forgetHidden("Slack")
+	want := map[string]struct{}{"goland": {}}
+	if got := psm.hiddenApps(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("hidden apps: %v, want %v", got, want)
+	}
+
+	if !psm.hidesApp(false, "GoLand") || psm.hidesApp(false, "Slack") || !psm.hidesApp(true, "Slack") {
+		t.Fatal("hide policy not applied")
+	}
+}
+
+func TestUnhideAndDelayEvents(t *testing.T) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "projsnap.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(hiddenBucketName)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("goland"), []byte("1"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	desktop := utils.NewFakeDesktop()
+	prev := utils.SetPlatform(desktop)
+	defer utils.SetPlatform(prev)
+	psm := &ProjSnapMaster{db: db, conf: &ProjSnapConfig{Apps: map[string]AppPolicy{
+		"goland": {Delay: Duration(time.Millisecond)},
+	}}}
+
+	events := make([]Event, 0)
+	psm.Subscribe(ObserverFunc(func(e Event) { events = append(events, e) }))
+	psm.unhideApps(context.Background(), []string{"goland", "Slack"})
+	psm.launched(context.Background(), "goland")
+	psm.launched(context.Background(), "Slack")
+	want := []Event{
+		{Type: EventAppOpening, App: "goland", Status: switchUnhide},
+		{Type: EventAppWaiting, App: "goland", Delay: Duration(time.Millisecond)},
+	}
+	for i := range events {
+		events[i].Time = time.Time{}
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("events: %+v", events)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+const (
+	hookPreTake    = "pre_take"
+	hookPostTake   = "post_take"
+	hookPreSwitch  = "pre_switch"
+	hookPostSwitch = "post_switch"
+	hookOnError    = "on_error"
+)
+
+var hookEvents = []string{hookPreTake, hookPostTake, hookPreSwitch, hookPostSwitch, hookOnError}
+
+const (
+	hookAbort    = "abort"
+	hookContinue = "continue"
+)
+
+// hookWaitDelay bounds how long the output of a killed hook is still read,
+// children such as `docker compose up` may keep it open.
+const hookWaitDelay = 2 * time.Second
+
+// Hook is a shell command run around a take, switch or restore. A failing
+// pre hook aborts the run by default, other hooks continue.
+type Hook struct {
+	Run       string   `json:"run"`
+	Timeout   Duration `json:"timeout,omitempty"`
+	OnFailure string   `json:"on_failure,omitempty"`
+}
+
+func isHookEvent(event string) bool {
+	for _, e := range hookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func (h Hook) aborts(event string) bool {
+	switch h.OnFailure {
+	case hookAbort:
+		return true
+	case hookContinue:
+		return false
+	}
+	return event == hookPreTake || event == hookPreSwitch
+}
+
+// hookRun is the snapshot a hook is run for, it is passed to the hook as
+// PROJSNAP_* environment variables.
+type hookRun struct {
+	action   string
+	snapshot string
+	err      error
+}
+
+func (psm *ProjSnapMaster) hooksOf(event, snapName string) []Hook {
+	hooks := append([]Hook{}, psm.conf.Hooks[event]...)
+	return append(hooks, psm.meta.ManifestSnapshots[snapName].Hooks[event]...)
+}
+
+func (psm *ProjSnapMaster) hookEnv(event string, run hookRun) []string {
+	env := append(os.Environ(),
+		"PROJSNAP_HOOK="+event,
+		"PROJSNAP_ACTION="+run.action,
+		"PROJSNAP_SNAPSHOT="+run.snapshot,
+		"PROJSNAP_SNAPSHOT_KEY="+psm.meta.ManifestSnapshots[run.snapshot].SnapshotKey,
+		"PROJSNAP_PROFILE="+psm.opt.profile,
+		"PROJSNAP_CONFIG_DIR="+psm.opt.configDir,
+	)
+	if run.err != nil {
+		env = append(env, "PROJSNAP_ERROR="+run.err.Error())
+	}
+	return env
+}
+
+// runHooks runs the config hooks of event and then the ones of the snapshot,
+// their output goes to the log line by line. The first failing hook that
+// aborts is returned.
+func (psm *ProjSnapMaster) runHooks(ctx context.Context, event string, run hookRun) error {
+	hooks := psm.hooksOf(event, run.snapshot)
+	if len(hooks) > 0 {
+		psm.emitPhase(event)
+	}
+	for _, hook := range hooks {
+		err := psm.runHook(ctx, event, hook, run)
+		if err == nil {
+			continue
+		}
+		if hook.aborts(event) {
+			return fmt.Errorf("%s hook %q fail: %w", event, hook.Run, err)
+		}
+		log.Printf("%s hook %q fail, continue, err: %v", event, hook.Run, err)
+	}
+	return nil
+}
+
+func (psm *ProjSnapMaster) runHook(ctx context.Context, event string, hook Hook, run hookRun) error {
+	timeout := time.Duration(hook.Timeout)
+	if timeout <= 0 {
+		timeout = time.Duration(psm.conf.HookTimeout)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	log.Printf("[%s] run: %s\n", event, hook.Run)
+	cmd := exec.CommandContext(ctx, "sh", "-c", hook.Run)
+	cmd.Env = psm.hookEnv(event, run)
+	// kill the whole process group on timeout, not only the shell
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = hookWaitDelay
+	pr, pw := io.Pipe()
+	cmd.Stdout, cmd.Stderr = pw, pw
+	logged := make(chan struct{})
+	go func() {
+		defer close(logged)
+		scanner := bufio.NewScanner(pr)
+		for scanner.Scan() {
+			log.Printf("[%s] %s\n", event, scanner.Text())
+		}
+		_, _ = io.Copy(io.Discard, pr)
+	}()
+	err := cmd.Run()
+	_ = pw.Close()
+	<-logged
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	return err
+}
+
+// withHooks runs fn between the pre and post hooks of stage (take or switch).
+// The on_error hooks run when a hook aborts, fn fails or a step of its report
+// failed. The done phase event closes the run.
+func (psm *ProjSnapMaster) withHooks(ctx context.Context, pre, post string, run hookRun, fn func() (*RestoreReport, error)) (report *RestoreReport, err error) {
+	psm.run = run
+	defer func() {
+		event := Event{Type: EventPhase, Phase: phaseDone}
+		if err != nil {
+			event.Error = err.Error()
+		}
+		psm.emit(event)
+	}()
+	onError := func(err error) {
+		run.err = err
+		if err := psm.runHooks(context.WithoutCancel(ctx), hookOnError, run); err != nil {
+			log.Println(err)
+		}
+	}
+	if err := psm.runHooks(ctx, pre, run); err != nil {
+		onError(err)
+		return nil, err
+	}
+	report, err = fn()
+	if err != nil {
+		onError(err)
+		return report, err
+	}
+	if report != nil && len(report.Failed()) > 0 {
+		onError(report)
+	}
+	if err := psm.runHooks(ctx, post, run); err != nil {
+		onError(err)
+		return report, err
+	}
+	return report, nil
+}
+
+// SetHooks replaces the hooks of event in snapshot snapName, no hooks removes
+// them.
+func (psm *ProjSnapMaster) SetHooks(snapName, event string, hooks []Hook) error {
+	snapshot, ok := psm.meta.ManifestSnapshots[snapName]
+	if !ok {
+		return fmt.Errorf("no found snapName: %s", snapName)
+	}
+	if !isHookEvent(event) {
+		return fmt.Errorf("unknown hook: %s", event)
+	}
+	for _, hook := range hooks {
+		if hook.OnFailure != "" && hook.OnFailure != hookAbort && hook.OnFailure != hookContinue {
+			return fmt.Errorf("unknown hook failure policy: %s", hook.OnFailure)
+		}
+	}
+	all := make(map[string][]Hook)
+	for e, hs := range snapshot.Hooks {
+		if e != event {
+			all[e] = hs
+		}
+	}
+	if len(hooks) > 0 {
+		all[event] = hooks
+	}
+	snapshot.Hooks = all
+	return psm.putManifest(snapshot)
+}
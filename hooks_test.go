@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunHooks(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	psm := &ProjSnapMaster{
+		opt: &ProjSnapOptions{profile: "work"},
+		conf: &ProjSnapConfig{HookTimeout: Duration(time.Second), Hooks: map[string][]Hook{
+			hookPreSwitch: {{Run: `echo "$PROJSNAP_ACTION $PROJSNAP_SNAPSHOT $PROJSNAP_PROFILE"`}},
+		}},
+		meta: &ProjSnapMeta{ManifestSnapshots: map[string]ProjSnapManifest{
+			"proj": {SnapshotName: "proj", Hooks: map[string][]Hook{
+				hookPreSwitch:  {{Run: "exit 3"}},
+				hookPostSwitch: {{Run: "exit 3"}, {Run: "sleep 5", Timeout: Duration(100 * time.Millisecond), OnFailure: hookContinue}},
+				hookOnError:    {{Run: `echo "fail: $PROJSNAP_ERROR"`}},
+			}},
+		}},
+	}
+	run := hookRun{action: actionSwitch, snapshot: "proj"}
+
+	if err := psm.runHooks(context.Background(), hookPreSwitch, run); err == nil {
+		t.Fatal("expect the failing pre hook to abort")
+	}
+	if !strings.Contains(buf.String(), "[pre_switch] switch proj work") {
+		t.Fatalf("hook output not logged: %s", buf.String())
+	}
+	if err := psm.runHooks(context.Background(), hookPostSwitch, run); err != nil {
+		t.Fatalf("post hooks continue by default, err: %v", err)
+	}
+	if !strings.Contains(buf.String(), "timed out") {
+		t.Fatalf("expect the sleep hook to time out: %s", buf.String())
+	}
+
+	_, err := psm.withHooks(context.Background(), hookPreTake, hookPostSwitch, run, func() (*RestoreReport, error) {
+		return nil, errors.New("boom")
+	})
+	if err == nil || !strings.Contains(buf.String(), "[on_error] fail: boom") {
+		t.Fatalf("expect on_error hook with the error, err: %v, log: %s", err, buf.String())
+	}
+}
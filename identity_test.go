@@ -0,0 +1,28 @@
+package main
+
+import (
+	"projsnap/apps"
+	"testing"
+)
+
+func TestPackerByIdentity(t *testing.T) {
+	psm := NewWorkspace(&ProjSnapOptions{})
+	psm.identities = map[string]*apps.AppIdentity{"访达": {BundleID: "com.apple.finder", PIDs: []int{412}}}
+
+	if _, ok := psm.GetPacker("访达").(apps.Finder); !ok {
+		t.Fatal("a localized Finder must be packed as Finder")
+	}
+	if _, ok := psm.GetPacker("Finder").(apps.Finder); !ok {
+		t.Fatal("the display name is the fallback")
+	}
+	if _, ok := psm.GetPacker("Slack").(apps.NormalPacker); !ok {
+		t.Fatal("unknown apps get the general packer")
+	}
+	conf := &apps.AppConfig{AppName: "Google Chrome 日本語", Identity: &apps.AppIdentity{BundleID: "com.google.chrome"}}
+	if _, ok := psm.packerFor(conf).(apps.Browser); !ok {
+		t.Fatal("a snapshot entry is unpacked by its captured identity")
+	}
+	if _, ok := psm.packerFor(&apps.AppConfig{AppName: "goland"}).(apps.JetBrains); !ok {
+		t.Fatal("old snapshots without identity go by display name")
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"log"
+	"time"
+)
+
+var (
+	journalBucketName = []byte("journal")
+	lastJournalKey    = []byte("last")
+)
+
+const (
+	actionRestore = "restore"
+	actionSwitch  = "switch"
+)
+
+const (
+	stepPending  = "pending"
+	stepOpened   = "opened"
+	stepQuit     = "quit"
+	stepHidden   = "hidden"
+	stepRestored = "restored"
+	stepSkipped  = "skipped"
+	stepFailed   = "failed"
+)
+
+// JournalStep is one app to open or quit, or one window to restore. Index
+// points into the snapshot the journal was started from.
+type JournalStep struct {
+	Index  int    `json:"index"`
+	App    string `json:"app"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// RestoreJournal records the progress of the last restore or switch, it is
+// saved after every step so an interrupted run can be retried or resumed.
+type RestoreJournal struct {
+	Action      string        `json:"action"`
+	Snapshot    string        `json:"snapshot"`
+	SnapshotKey string        `json:"snapshot_key"`
+	Started     int64         `json:"started"`
+	Done        bool          `json:"done"`
+	Hide        bool          `json:"hide,omitempty"`
+	Apps        []JournalStep `json:"apps"`
+	Quits       []JournalStep `json:"quits"`
+	Windows     []JournalStep `json:"windows"`
+}
+
+func (psm *ProjSnapMaster) newJournal(action, snapName string, appSnapshots []AppSnapshot, quits []string) *RestoreJournal {
+	j := &RestoreJournal{
+		Action:      action,
+		Snapshot:    snapName,
+		SnapshotKey: psm.meta.ManifestSnapshots[snapName].SnapshotKey,
+		Started:     time.Now().Unix(),
+		Hide:        psm.opt.hide,
+		Apps:        make([]JournalStep, 0),
+		Quits:       make([]JournalStep, 0),
+		Windows:     make([]JournalStep, 0),
+	}
+	for i, conf := range appSnapshots {
+		j.Apps = append(j.Apps, JournalStep{Index: i, App: conf.AppName, Status: stepPending})
+		if conf.WindowInfo != nil {
+			j.Windows = append(j.Windows, JournalStep{Index: i, App: conf.AppName, Status: stepPending})
+		}
+	}
+	for _, app := range quits {
+		j.Quits = append(j.Quits, JournalStep{App: app, Status: stepPending})
+	}
+	return j
+}
+
+func (psm *ProjSnapMaster) saveJournal(j *RestoreJournal) error {
+	data, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	return psm.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(journalBucketName).Put(lastJournalKey, data)
+	})
+}
+
+func (psm *ProjSnapMaster) LastJournal() (*RestoreJournal, error) {
+	var data []byte
+	_ = psm.db.View(func(tx *bolt.Tx) error {
+		data = tx.Bucket(journalBucketName).Get(lastJournalKey)
+		return nil
+	})
+	if data == nil {
+		return nil, errors.New("no restore or switch has been run yet")
+	}
+	j := &RestoreJournal{}
+	if err := json.Unmarshal(data, j); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+var stepEvents = map[string]string{
+	kindApp:    EventAppOpened,
+	kindQuit:   EventAppQuit,
+	kindWindow: EventWindowRestored,
+}
+
+func (j *RestoreJournal) steps(kind string) []JournalStep {
+	switch kind {
+	case kindApp:
+		return j.Apps
+	case kindQuit:
+		return j.Quits
+	}
+	return j.Windows
+}
+
+// markStep records the outcome of step, one of the steps of kind, persists
+// the journal right away and tells the observers.
+func (psm *ProjSnapMaster) markStep(j *RestoreJournal, kind string, step *JournalStep, status string, err error) {
+	psm.mu.Lock()
+	step.Status, step.Error = status, ""
+	if err != nil {
+		step.Status, step.Error = stepFailed, err.Error()
+	}
+	if err := psm.saveJournal(j); err != nil {
+		log.Printf("save journal fail, err: %v", err)
+	}
+	event := Event{Type: stepEvents[kind], App: step.App, Status: step.Status, Error: step.Error}
+	for _, s := range j.steps(kind) {
+		event.Total++
+		if s.Status != stepPending {
+			event.Done++
+		}
+	}
+	psm.mu.Unlock()
+	psm.emit(event)
+}
+
+func isPendingStep(step JournalStep) bool {
+	return step.Status == stepPending
+}
+
+func isUnfinishedStep(step JournalStep) bool {
+	return step.Status == stepPending || step.Status == stepFailed
+}
+
+// runJournal opens, quits and restores the windows of the steps selected by
+// want, recording every outcome in j. Failed steps do not stop the run, they
+// are collected in the returned report.
+func (psm *ProjSnapMaster) runJournal(ctx context.Context, j *RestoreJournal, appSnapshots []AppSnapshot, want func(JournalStep) bool) (*RestoreReport, error) {
+	start := time.Now()
+	report := func() *RestoreReport {
+		r := newRestoreReport(j)
+		r.Elapsed = time.Since(start)
+		return r
+	}
+	todo := make([]int, 0)
+	subset := make([]AppSnapshot, 0)
+	for i, step := range j.Apps {
+		if want(step) {
+			todo = append(todo, i)
+			subset = append(subset, appSnapshots[step.Index])
+		}
+	}
+	quits, quitNames := make([]int, 0), make([]string, 0)
+	for i, step := range j.Quits {
+		if want(step) {
+			quits = append(quits, i)
+			quitNames = append(quitNames, step.App)
+		}
+	}
+
+	// refuse a dependency cycle or an unforced force quit before anything is touched
+	psm.policies = psm.meta.ManifestSnapshots[j.Snapshot].Policies
+	openNames := make([]string, 0)
+	for _, group := range groupAppSnapshots(subset) {
+		openNames = append(openNames, group.app)
+	}
+	if _, err := psm.openWaves(openNames); err != nil {
+		return nil, err
+	}
+	quitOrder, err := psm.quitWaves(quitNames)
+	if err != nil {
+		return nil, err
+	}
+	if err := psm.checkForceQuit(quitNames, j.Hide); err != nil {
+		return nil, err
+	}
+
+	j.Done = false
+	if err := psm.saveJournal(j); err != nil {
+		return nil, err
+	}
+	opened := func(k int, err error) {
+		psm.markStep(j, kindApp, &j.Apps[todo[k]], stepOpened, err)
+	}
+	// bring back what an earlier switch hid before comparing it with the target
+	psm.unhideApps(ctx, openNames)
+
+	realRunning := make(map[string]struct{})
+	if j.Action == actionSwitch {
+		if realRunning, err = psm.getAllApplication(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	// restore the windows of every app as soon as it is up
+	launched := make(chan struct{})
+	windowsDone := make(chan error, 1)
+	go func() {
+		windowsDone <- psm.restoreWindowsWhenReady(ctx, j, appSnapshots, want, launched)
+	}()
+	waitWindows := func() error {
+		close(launched)
+		return <-windowsDone
+	}
+
+	psm.emitPhase(phaseOpen)
+	if j.Action == actionSwitch {
+		// open missing apps, apps already in the target state are untouched
+		err = psm.applyAppSwitch(ctx, psm.planAppSwitch(ctx, subset, realRunning), opened)
+	} else {
+		// open app, ignore current whether is opened
+		err = psm.openAppFromSnapshot(ctx, subset, realRunning, opened)
+	}
+	if err != nil {
+		_ = waitWindows()
+		return report(), err
+	}
+
+	// a cancelled run must not quit any more apps, the rest stays pending
+	if err := ctx.Err(); err != nil {
+		_ = waitWindows()
+		return report(), err
+	}
+
+	// close or hide other app
+	psm.emitPhase(phaseQuit)
+	psm.runWaves(ctx, quitNames, quitOrder, func(ctx context.Context, k int) {
+		app := quitNames[k]
+		if psm.hidesApp(j.Hide, app) {
+			psm.markStep(j, kindQuit, &j.Quits[quits[k]], stepHidden, psm.hideApp(ctx, app))
+			return
+		}
+		psm.markStep(j, kindQuit, &j.Quits[quits[k]], stepQuit, psm.quitApp(ctx, app))
+	})
+	if err := ctx.Err(); err != nil {
+		_ = waitWindows()
+		return report(), err
+	}
+	psm.emitPhase(phaseWindows)
+	if err := waitWindows(); err != nil {
+		return report(), err
+	}
+
+	j.Done = true
+	return report(), psm.saveJournal(j)
+}
+
+func (psm *ProjSnapMaster) continueJournal(ctx context.Context, want func(JournalStep) bool) (*RestoreReport, error) {
+	j, err := psm.LastJournal()
+	if err != nil {
+		return nil, err
+	}
+	snapshot, ok := psm.meta.ManifestSnapshots[j.Snapshot]
+	if !ok || snapshot.SnapshotKey != j.SnapshotKey {
+		return nil, fmt.Errorf("snapshot %s was removed or retaken since the last %s", j.Snapshot, j.Action)
+	}
+	appSnapshots, err := psm.loadRestoreSnapshot(j.Snapshot)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Continue %s %s started at %s\n", j.Action, j.Snapshot, time.Unix(j.Started, 0).String())
+	return psm.withHooks(ctx, hookPreSwitch, hookPostSwitch, hookRun{action: j.Action, snapshot: j.Snapshot}, func() (*RestoreReport, error) {
+		appSnapshots, dropped, err := psm.preflight(ctx, j.Snapshot, appSnapshots)
+		if err != nil {
+			return nil, err
+		}
+		skipSteps(j, appSnapshots, dropped)
+		return psm.runJournal(ctx, j, appSnapshots, want)
+	})
+}
+
+// Retry re-runs the failed and pending steps of the last restore or switch.
+func (psm *ProjSnapMaster) Retry(ctx context.Context) (*RestoreReport, error) {
+	return psm.continueJournal(ctx, isUnfinishedStep)
+}
+
+// Resume continues an interrupted restore or switch from its pending steps.
+func (psm *ProjSnapMaster) Resume(ctx context.Context) (*RestoreReport, error) {
+	j, err := psm.LastJournal()
+	if err != nil {
+		return nil, err
+	}
+	if j.Done {
+		return nil, fmt.Errorf("the last %s of %s was not interrupted", j.Action, j.Snapshot)
+	}
+	return psm.continueJournal(ctx, isPendingStep)
+}
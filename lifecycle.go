@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"projsnap/utils"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AppPolicy declares when an app opens and quits relative to the others.
+// Apps open by ascending priority once every app of After is up, Delay is
+// waited after the app was launched, and apps quit by ascending quit_order
+// with dependents quitting before what they depend on. A switch hides the
+// apps with Hide instead of quitting them. Quit is the quit strategy of the
+// app: graceful (the default), term or kill once it outlived quit_timeout.
+type AppPolicy struct {
+	Priority  int      `json:"priority,omitempty"`
+	After     []string `json:"after,omitempty"`
+	Delay     Duration `json:"delay,omitempty"`
+	QuitOrder int      `json:"quit_order,omitempty"`
+	Hide      bool     `json:"hide,omitempty"`
+	Quit      string   `json:"quit,omitempty"`
+}
+
+// defaultPolicies apply when neither the snapshot nor the config has one.
+var defaultPolicies = map[string]AppPolicy{
+	// iTerm2 usually hosts projsnap itself
+	"iterm2": {QuitOrder: 100},
+}
+
+func findPolicy(policies map[string]AppPolicy, app string) (AppPolicy, bool) {
+	for name, policy := range policies {
+		if strings.EqualFold(name, app) {
+			return policy, true
+		}
+	}
+	return AppPolicy{}, false
+}
+
+// policyOf looks app up in the running snapshot, then the config, then the
+// built-in defaults.
+func (psm *ProjSnapMaster) policyOf(app string) AppPolicy {
+	for _, policies := range []map[string]AppPolicy{psm.policies, psm.conf.Apps, defaultPolicies} {
+		if policy, ok := findPolicy(policies, app); ok {
+			return policy
+		}
+	}
+	return AppPolicy{}
+}
+
+// orderWaves sorts 0..len(names)-1 into waves, an app only enters a wave once
+// all its deps are in earlier waves, and every wave holds the ready apps with
+// the lowest key. A dependency cycle is an error.
+func orderWaves(names []string, deps map[int][]int, key func(int) int) ([][]int, error) {
+	indegree := make([]int, len(names))
+	dependents := make(map[int][]int)
+	for i, ds := range deps {
+		for _, d := range ds {
+			indegree[i]++
+			dependents[d] = append(dependents[d], i)
+		}
+	}
+	done := make([]bool, len(names))
+	waves := make([][]int, 0)
+	for left := len(names); left > 0; {
+		ready := make([]int, 0)
+		for i := range names {
+			if !done[i] && indegree[i] == 0 {
+				ready = append(ready, i)
+			}
+		}
+		if len(ready) == 0 {
+			cycle := make([]string, 0)
+			for i, name := range names {
+				if !done[i] {
+					cycle = append(cycle, name)
+				}
+			}
+			sort.Strings(cycle)
+			return nil, fmt.Errorf("dependency cycle between %s", strings.Join(cycle, ", "))
+		}
+		lowest := key(ready[0])
+		for _, i := range ready {
+			lowest = min(lowest, key(i))
+		}
+		wave := make([]int, 0)
+		for _, i := range ready {
+			if key(i) == lowest {
+				wave = append(wave, i)
+			}
+		}
+		for _, i := range wave {
+			done[i] = true
+			left--
+			for _, dep := range dependents[i] {
+				indegree[dep]--
+			}
+		}
+		waves = append(waves, wave)
+	}
+	return waves, nil
+}
+
+// dependencies maps every app of names to the apps of names it must come after.
+func (psm *ProjSnapMaster) dependencies(names []string) map[int][]int {
+	index := make(map[string]int)
+	for i, name := range names {
+		index[strings.ToLower(name)] = i
+	}
+	deps := make(map[int][]int)
+	for i, name := range names {
+		for _, after := range psm.policyOf(name).After {
+			if d, ok := index[strings.ToLower(after)]; ok && d != i {
+				deps[i] = append(deps[i], d)
+			}
+		}
+	}
+	return deps
+}
+
+func (psm *ProjSnapMaster) openWaves(names []string) ([][]int, error) {
+	return orderWaves(names, psm.dependencies(names), func(i int) int {
+		return psm.policyOf(names[i]).Priority
+	})
+}
+
+// quitWaves reverses the dependencies, a browser quits before its VPN client.
+func (psm *ProjSnapMaster) quitWaves(names []string) ([][]int, error) {
+	reversed := make(map[int][]int)
+	for i, ds := range psm.dependencies(names) {
+		for _, d := range ds {
+			reversed[d] = append(reversed[d], i)
+		}
+	}
+	return orderWaves(names, reversed, func(i int) int {
+		return psm.policyOf(names[i]).QuitOrder
+	})
+}
+
+// runWaves runs fn for the apps of every wave on the worker pool, a wave only
+// starts once the previous one is done.
+func (psm *ProjSnapMaster) runWaves(ctx context.Context, names []string, waves [][]int, fn func(ctx context.Context, i int)) {
+	for _, wave := range waves {
+		waveNames := make([]string, 0, len(wave))
+		for _, i := range wave {
+			waveNames = append(waveNames, names[i])
+		}
+		psm.runApps(ctx, waveNames, func(ctx context.Context, k int) {
+			fn(ctx, wave[k])
+		})
+	}
+}
+
+// launched waits the post-launch delay of app.
+func (psm *ProjSnapMaster) launched(ctx context.Context, app string) {
+	if delay := time.Duration(psm.policyOf(app).Delay); delay > 0 {
+		psm.emit(Event{Type: EventAppWaiting, App: app, Delay: Duration(delay)})
+		_ = utils.Sleep(ctx, delay)
+	}
+}
+
+func (psm *ProjSnapMaster) SetPolicy(snapName, app string, policy *AppPolicy) error {
+	snapshot, ok := psm.meta.ManifestSnapshots[snapName]
+	if !ok {
+		return fmt.Errorf("no found snapName: %s", snapName)
+	}
+	if policy != nil && policy.Quit != "" && policy.Quit != quitGraceful && !isForceQuit(policy.Quit) {
+		return fmt.Errorf("unknown quit strategy: %s", policy.Quit)
+	}
+	policies := make(map[string]AppPolicy)
+	for name, p := range snapshot.Policies {
+		if !strings.EqualFold(name, app) {
+			policies[name] = p
+		}
+	}
+	if policy != nil {
+		policies[app] = *policy
+	}
+	snapshot.Policies = policies
+	return psm.putManifest(snapshot)
+}
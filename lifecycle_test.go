@@ -0,0 +1,43 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOpenAndQuitWaves(t *testing.T) {
+	psm := &ProjSnapMaster{conf: &ProjSnapConfig{Apps: map[string]AppPolicy{
+		"Microsoft Edge": {After: []string{"Tunnelblick"}},
+		"goland":         {After: []string{"Docker"}},
+		"Slack":          {Priority: 10},
+	}}}
+	names := []string{"goland", "Slack", "Microsoft Edge", "Docker", "Tunnelblick", "iTerm2"}
+
+	waves, err := psm.openWaves(names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]int{{3, 4, 5}, {0, 2}, {1}}
+	if !reflect.DeepEqual(waves, want) {
+		t.Fatalf("open waves: %v, want %v", waves, want)
+	}
+
+	waves, err = psm.quitWaves(names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = [][]int{{0, 1, 2}, {3, 4}, {5}}
+	if !reflect.DeepEqual(waves, want) {
+		t.Fatalf("quit waves: %v, want %v", waves, want)
+	}
+}
+
+func TestWavesCycle(t *testing.T) {
+	psm := &ProjSnapMaster{conf: &ProjSnapConfig{Apps: map[string]AppPolicy{
+		"a": {After: []string{"b"}},
+		"b": {After: []string{"a"}},
+	}}}
+	if _, err := psm.openWaves([]string{"a", "b", "c"}); err == nil {
+		t.Fatal("expect dependency cycle")
+	}
+}
@@ -1,11 +1,17 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"fmt"
 	"github.com/spf13/cobra"
 	"log"
+	"maps"
 	"os"
-	"projsnap/utils"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
 	"time"
 )
 
@@ -16,15 +22,140 @@ System Events 访问其他 app 的窗口信息需要你授权：
 启用当前运行脚本的终端（如 iTerm、Terminal、Script Editor、你的 Go 程序等）
 */
 
-var configDir, _ = utils.ExpandUser("~/.projsnap/")
+var configDir, dataDir, _ = profileDirs(os.Getenv("PROJSNAP_PROFILE"))
+var profileName string
 var quitFlag bool
 var snapName string
 var rmIndex int
+var dstProfile string
+var dstName string
+var moveFlag bool
+var dryRunFlag bool
+var jsonFlag bool
+var allFlag bool
+var failOnFlag string
+var jobsFlag int
+var policyApp string
+var policyFlags AppPolicy
+var policyDelay time.Duration
+var clearFlag bool
+var hideFlag bool
+var forceFlag bool
+var hookEvent string
+var eventsFlag string
+var skipInvalidFlag bool
+var remapFlags []string
+var remapFrom string
+var remapTo string
+var mapFlags []string
+var hookFlags Hook
+var hookTimeout time.Duration
+
+func printPlan(plan *ProjSnapPlan, err error) {
+	if err != nil {
+		log.Fatalf("build plan fail, err: %v", err)
+	}
+	if jsonFlag {
+		if err := plan.WriteJSON(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	plan.Print(os.Stdout)
+}
+
+// finishReport prints the per app and window results and exits non-zero when
+// the failure policy (--fail-on, or fail_on in config) is hit.
+func finishReport(ws *ProjSnapMaster, action string, report *RestoreReport, err error) {
+	if report != nil && eventsFlag == eventsJSON {
+		// keep stdout to the events
+		report.Print(os.Stderr)
+	} else if report != nil {
+		report.Print(os.Stdout)
+	}
+	if err != nil {
+		log.Printf("%s occur error: %v\n", action, err)
+		_ = ws.Close()
+		os.Exit(1)
+	}
+	policy := failOnFlag
+	if policy == "" {
+		policy = ws.conf.FailOn
+	}
+	if err := report.Check(policy, ws.conf.CriticalApps); err != nil {
+		log.Println(err)
+		_ = ws.Close()
+		os.Exit(1)
+	}
+}
+
+// subscribeEvents renders the progress of ws as text lines or, with
+// --events=json, as newline-delimited JSON events on stdout.
+func subscribeEvents(ws *ProjSnapMaster) {
+	switch eventsFlag {
+	case eventsText, "":
+		ws.Subscribe(textProgress(os.Stdout))
+	case eventsJSON:
+		ws.Subscribe(jsonProgress(os.Stdout))
+	default:
+		log.Fatalf("unknown events format: %s", eventsFlag)
+	}
+}
+
+func newOptions() *ProjSnapOptions {
+	remap := make([]RemapRule, 0, len(remapFlags))
+	for _, s := range remapFlags {
+		rule, err := parseRemapRule(s)
+		if err != nil {
+			log.Fatal(err)
+		}
+		remap = append(remap, rule)
+	}
+	aliases := make(map[string]string)
+	for _, s := range mapFlags {
+		from, to, err := parseAlias(s)
+		if err != nil {
+			log.Fatal(err)
+		}
+		aliases[from] = to
+	}
+	return &ProjSnapOptions{
+		profile:   profileName,
+		configDir: configDir,
+		dataDir:   dataDir,
+		workers:   jobsFlag,
+		force:     forceFlag,
+		remap:     remap,
+		aliases:   aliases,
+
+		skipInvalid: skipInvalidFlag,
+		confirm:     confirmSkip,
+	}
+}
+
+// confirmSkip asks on a terminal whether to go on without the invalid items.
+func confirmSkip(result *CheckResult) bool {
+	if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	result.Print(os.Stderr)
+	fmt.Fprint(os.Stderr, "Continue and skip them? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
 
 var rootCmd = &cobra.Command{
 	Use:   "projsnap",
 	Short: "Save the current snapshot and restore it when needed",
 	Long:  "Save the current snapshot and restore it when needed",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) (err error) {
+		if profileName == "" {
+			profileName = defaultProfile
+		}
+		configDir, dataDir, err = profileDirs(profileName)
+		return err
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("Use `projsnap snapshot` or `projsnap restore` to start.")
 	},
@@ -39,15 +170,23 @@ var snapshotCmd = &cobra.Command{
 			log.Println("You should input snapName(--name [snapshot] or -n [snapshot])")
 			return
 		}
-		ws := NewWorkspace(&ProjSnapOptions{
-			quit:      quitFlag,
-			configDir: configDir,
-		})
+		if isUndoSnapshot(snapName) {
+			log.Printf("snapName can not start with %s\n", undoPrefix)
+			return
+		}
+		opt := newOptions()
+		opt.quit = quitFlag
+		ws := NewWorkspace(opt)
 		if err := ws.Open(); err != nil {
 			log.Fatal(err)
 		}
 		defer ws.Close()
-		if ok, err := ws.SaveSnapshot(snapName); !ok || err != nil {
+		if dryRunFlag {
+			printPlan(ws.PlanSave(cmd.Context(), snapName))
+			return
+		}
+		subscribeEvents(ws)
+		if ok, err := ws.SaveSnapshot(cmd.Context(), snapName); !ok || err != nil {
 			log.Printf("SaveSnapshot fail, ok: %v, err: %v\n", ok, err)
 		}
 	},
@@ -62,16 +201,20 @@ var switchCmd = &cobra.Command{
 			log.Println("You should input snapName(--name [snapshot] or -n [snapshot])")
 			return
 		}
-		ws := NewWorkspace(&ProjSnapOptions{
-			configDir: configDir,
-		})
+		opt := newOptions()
+		opt.hide = hideFlag
+		ws := NewWorkspace(opt)
 		if err := ws.Open(); err != nil {
 			log.Fatal(err)
 		}
 		defer ws.Close()
-		if err := ws.SwitchSnapshot(snapName); err != nil {
-			log.Printf("SwitchSnapshot occur error: %v\n", err)
+		if dryRunFlag {
+			printPlan(ws.PlanSwitch(cmd.Context(), snapName))
+			return
 		}
+		subscribeEvents(ws)
+		report, err := ws.SwitchSnapshot(cmd.Context(), snapName)
+		finishReport(ws, "SwitchSnapshot", report, err)
 	},
 }
 
@@ -84,16 +227,18 @@ var restoreCmd = &cobra.Command{
 			log.Println("You should input snapName(--name [snapshot] or -n [snapshot])")
 			return
 		}
-		ws := NewWorkspace(&ProjSnapOptions{
-			configDir: configDir,
-		})
+		ws := NewWorkspace(newOptions())
 		if err := ws.Open(); err != nil {
 			log.Fatal(err)
 		}
 		defer ws.Close()
-		if err := ws.RestoreSnapshot(snapName); err != nil {
-			log.Printf("RestoreSnapshot occur error: %v\n", err)
+		if dryRunFlag {
+			printPlan(ws.PlanRestore(cmd.Context(), snapName))
+			return
 		}
+		subscribeEvents(ws)
+		report, err := ws.RestoreSnapshot(cmd.Context(), snapName)
+		finishReport(ws, "RestoreSnapshot", report, err)
 	},
 }
 
@@ -102,15 +247,13 @@ var listSnapshotCmd = &cobra.Command{
 	Aliases: []string{"ls", "ll"},
 	Short:   "list ManifestSnapshots",
 	Run: func(cmd *cobra.Command, args []string) {
-		ws := NewWorkspace(&ProjSnapOptions{
-			configDir: configDir,
-		})
+		ws := NewWorkspace(newOptions())
 		if err := ws.Open(); err != nil {
 			log.Fatal(err)
 		}
 		defer ws.Close()
 		i := 1
-		for _, snapshot := range ws.ListSnapshots() {
+		for _, snapshot := range ws.ListSnapshots(allFlag) {
 			if snapshot.SnapshotName == snapshot.SnapshotKey {
 				fmt.Printf("[%d] %s\t%s\n", i, snapshot.SnapshotKey, time.Unix(snapshot.Ctime, 0).String())
 			} else {
@@ -133,9 +276,7 @@ var rmSnapshotCmd = &cobra.Command{
 			log.Println("You should input snapName(--name [snapshot] or -n [snapshot])")
 			return
 		}
-		ws := NewWorkspace(&ProjSnapOptions{
-			configDir: configDir,
-		})
+		ws := NewWorkspace(newOptions())
 		if err := ws.Open(); err != nil {
 			log.Fatal(err)
 		}
@@ -148,17 +289,320 @@ var rmSnapshotCmd = &cobra.Command{
 	},
 }
 
+var undoCmd = &cobra.Command{
+	Use:   "undo",
+	Short: "return to the desktop as it was before the last switch or restore",
+	Run: func(cmd *cobra.Command, args []string) {
+		ws := NewWorkspace(newOptions())
+		if err := ws.Open(); err != nil {
+			log.Fatal(err)
+		}
+		defer ws.Close()
+		subscribeEvents(ws)
+		report, err := ws.Undo(cmd.Context())
+		finishReport(ws, "Undo", report, err)
+	},
+}
+
+var retryCmd = &cobra.Command{
+	Use:   "retry",
+	Short: "re-run the failed or pending steps of the last restore or switch",
+	Run: func(cmd *cobra.Command, args []string) {
+		ws := NewWorkspace(newOptions())
+		if err := ws.Open(); err != nil {
+			log.Fatal(err)
+		}
+		defer ws.Close()
+		subscribeEvents(ws)
+		report, err := ws.Retry(cmd.Context())
+		finishReport(ws, "Retry", report, err)
+	},
+}
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "continue an interrupted restore or switch",
+	Run: func(cmd *cobra.Command, args []string) {
+		ws := NewWorkspace(newOptions())
+		if err := ws.Open(); err != nil {
+			log.Fatal(err)
+		}
+		defer ws.Close()
+		subscribeEvents(ws)
+		report, err := ws.Resume(cmd.Context())
+		finishReport(ws, "Resume", report, err)
+	},
+}
+
+var policyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "show or set the open and quit order of apps in a snapshot",
+	Run: func(cmd *cobra.Command, args []string) {
+		if snapName == "" {
+			log.Println("You should input snapName(--name [snapshot] or -n [snapshot])")
+			return
+		}
+		ws := NewWorkspace(newOptions())
+		if err := ws.Open(); err != nil {
+			log.Fatal(err)
+		}
+		defer ws.Close()
+		if policyApp != "" {
+			var policy *AppPolicy
+			if !clearFlag {
+				policyFlags.Delay = Duration(policyDelay)
+				policy = &policyFlags
+			}
+			if err := ws.SetPolicy(snapName, policyApp, policy); err != nil {
+				log.Fatalf("set policy fail, err: %v", err)
+			}
+		}
+		snapshot, ok := ws.ListSnapshots(true)[snapName]
+		if !ok {
+			log.Fatalf("no found snapName: %s", snapName)
+		}
+		for _, app := range slices.Sorted(maps.Keys(snapshot.Policies)) {
+			p := snapshot.Policies[app]
+			fmt.Printf("%s\tpriority: %d, after: %v, delay: %s, quit_order: %d, hide: %v, quit: %s\n",
+				app, p.Priority, p.After, time.Duration(p.Delay), p.QuitOrder, p.Hide, p.Quit)
+		}
+	},
+}
+
+var checkCmd = &cobra.Command{
+	Use:   "check [snapshot]",
+	Short: "check that the apps, paths, displays and spaces of a snapshot are present",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 1 {
+			snapName = args[0]
+		}
+		if snapName == "" {
+			log.Println("You should input snapName(--name [snapshot] or -n [snapshot])")
+			return
+		}
+		ws := NewWorkspace(newOptions())
+		if err := ws.Open(); err != nil {
+			log.Fatal(err)
+		}
+		defer ws.Close()
+		result, err := ws.CheckSnapshot(cmd.Context(), snapName)
+		if err != nil {
+			log.Fatalf("check fail, err: %v", err)
+		}
+		result.Print(os.Stdout)
+		if len(result.Issues) > 0 {
+			_ = ws.Close()
+			os.Exit(1)
+		}
+	},
+}
+
+var remapCmd = &cobra.Command{
+	Use:   "remap [snapshot]",
+	Short: "rewrite the paths stored in a snapshot, e.g. after a repo moved",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 1 {
+			snapName = args[0]
+		}
+		if snapName == "" || remapFrom == "" || remapTo == "" {
+			log.Println("You should input snapName and paths([snapshot] --from [path] --to [path])")
+			return
+		}
+		ws := NewWorkspace(newOptions())
+		if err := ws.Open(); err != nil {
+			log.Fatal(err)
+		}
+		defer ws.Close()
+		changed, err := ws.RemapSnapshot(snapName, []RemapRule{{From: remapFrom, To: remapTo}})
+		if err != nil {
+			log.Fatalf("remap fail, err: %v", err)
+		}
+		fmt.Printf("remap %s success, %d paths changed\n", snapName, changed)
+	},
+}
+
+var hookCmd = &cobra.Command{
+	Use:   "hook",
+	Short: "show or add the commands run around take and switch of a snapshot",
+	Run: func(cmd *cobra.Command, args []string) {
+		if snapName == "" {
+			log.Println("You should input snapName(--name [snapshot] or -n [snapshot])")
+			return
+		}
+		ws := NewWorkspace(newOptions())
+		if err := ws.Open(); err != nil {
+			log.Fatal(err)
+		}
+		defer ws.Close()
+		snapshot, ok := ws.ListSnapshots(true)[snapName]
+		if !ok {
+			log.Fatalf("no found snapName: %s", snapName)
+		}
+		if hookEvent != "" && (clearFlag || hookFlags.Run != "") {
+			hooks := make([]Hook, 0)
+			if !clearFlag {
+				hookFlags.Timeout = Duration(hookTimeout)
+				hooks = append(snapshot.Hooks[hookEvent], hookFlags)
+			}
+			if err := ws.SetHooks(snapName, hookEvent, hooks); err != nil {
+				log.Fatalf("set hook fail, err: %v", err)
+			}
+			snapshot = ws.ListSnapshots(true)[snapName]
+		}
+		for _, event := range hookEvents {
+			for _, hook := range snapshot.Hooks[event] {
+				fmt.Printf("%s\t%s\ttimeout: %s, on_failure: %s\n", event, hook.Run, time.Duration(hook.Timeout), hook.OnFailure)
+			}
+		}
+	},
+}
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "manage isolated profiles",
+}
+
+var profileListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls", "ll"},
+	Short:   "list profiles",
+	Run: func(cmd *cobra.Command, args []string) {
+		profiles, err := listProfiles()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, profile := range profiles {
+			if profile == profileName {
+				fmt.Printf("* %s\n", profile)
+			} else {
+				fmt.Printf("  %s\n", profile)
+			}
+		}
+	},
+}
+
+var profileCreateCmd = &cobra.Command{
+	Use:   "create [profile]",
+	Short: "create a new profile",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := createProfile(args[0]); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("create profile %s success!\n", args[0])
+	},
+}
+
+var profileCopyCmd = &cobra.Command{
+	Use:   "copy-snapshot",
+	Short: "copy a snapshot of the current profile to another profile",
+	Run: func(cmd *cobra.Command, args []string) {
+		if snapName == "" || dstProfile == "" {
+			log.Println("You should input snapName and profile(--name [snapshot] --to [profile])")
+			return
+		}
+		if dstProfile == profileName {
+			log.Println("destination profile is the current profile")
+			return
+		}
+		if ok, err := profileExists(dstProfile); err != nil || !ok {
+			log.Fatalf("no found profile: %s, err: %v", dstProfile, err)
+		}
+		if dstName == "" {
+			dstName = snapName
+		}
+		ws := NewWorkspace(newOptions())
+		if err := ws.Open(); err != nil {
+			log.Fatal(err)
+		}
+		defer ws.Close()
+
+		dstConfigDir, dstDataDir, err := profileDirs(dstProfile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		dst := NewWorkspace(&ProjSnapOptions{
+			profile:   dstProfile,
+			configDir: dstConfigDir,
+			dataDir:   dstDataDir,
+		})
+		if err := dst.Open(); err != nil {
+			log.Fatal(err)
+		}
+		defer dst.Close()
+
+		if err := ws.CopySnapshot(snapName, dst, dstName); err != nil {
+			log.Fatalf("copy snapshot fail, err: %v", err)
+		}
+		if moveFlag {
+			if err := ws.RemoveSnapshots(snapName); err != nil {
+				log.Fatalf("remove snapshot fail, err: %v", err)
+			}
+		}
+		fmt.Printf("copy %s to %s(%s) success!\n", snapName, dstProfile, dstName)
+	},
+}
+
 func init() {
+	rootCmd.PersistentFlags().StringVarP(&profileName, "profile", "p", os.Getenv("PROJSNAP_PROFILE"), "profile name (env PROJSNAP_PROFILE)")
 	snapshotCmd.Flags().BoolVarP(&quitFlag, "quit", "q", false, "Exit when saving snapshot")
 	snapshotCmd.Flags().StringVarP(&snapName, "name", "n", "", "snapshot name")
 	switchCmd.Flags().StringVarP(&snapName, "name", "n", "", "snapshot name")
+	switchCmd.Flags().BoolVar(&hideFlag, "hide", false, "hide the apps not in the snapshot instead of quitting them")
 	restoreCmd.Flags().StringVarP(&snapName, "name", "n", "", "snapshot name")
 	rmSnapshotCmd.Flags().StringVarP(&snapName, "name", "n", "", "snapshot name")
-	rootCmd.AddCommand(snapshotCmd, restoreCmd, listSnapshotCmd, rmSnapshotCmd, switchCmd)
+	for _, cmd := range []*cobra.Command{snapshotCmd, switchCmd, restoreCmd} {
+		cmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "print the plan without launching or quitting any app")
+		cmd.Flags().BoolVar(&jsonFlag, "json", false, "print the dry-run plan as JSON")
+	}
+	for _, cmd := range []*cobra.Command{switchCmd, restoreCmd, undoCmd, retryCmd, resumeCmd} {
+		cmd.Flags().StringVar(&failOnFlag, "fail-on", "", "exit non-zero when any, all or critical apps failed")
+		cmd.Flags().BoolVar(&skipInvalidFlag, "skip-invalid", false, "skip the apps, paths and windows the preflight check found invalid")
+	}
+	for _, cmd := range []*cobra.Command{switchCmd, restoreCmd, undoCmd, retryCmd, resumeCmd, checkCmd} {
+		cmd.Flags().StringArrayVar(&remapFlags, "remap", nil, "rewrite paths under from to to while restoring (from=to, repeatable)")
+		cmd.Flags().StringArrayVar(&mapFlags, "map", nil, "restore a captured app with an installed one (captured=installed, repeatable)")
+	}
+	for _, cmd := range []*cobra.Command{snapshotCmd, switchCmd, restoreCmd, undoCmd, retryCmd, resumeCmd} {
+		cmd.Flags().IntVarP(&jobsFlag, "jobs", "j", 0, "number of apps packed or opened concurrently (default workers in config)")
+		cmd.Flags().BoolVar(&forceFlag, "force", false, "allow SIGTERM or SIGKILL to apps whose quit strategy is term or kill")
+		cmd.Flags().StringVar(&eventsFlag, "events", eventsText, "progress as text lines, or json for one event per line")
+	}
+	policyCmd.Flags().StringVarP(&snapName, "name", "n", "", "snapshot name")
+	policyCmd.Flags().StringVar(&policyApp, "app", "", "app to set the policy of")
+	policyCmd.Flags().IntVar(&policyFlags.Priority, "priority", 0, "apps open by ascending priority")
+	policyCmd.Flags().StringSliceVar(&policyFlags.After, "after", nil, "apps that must be up before this one opens")
+	policyCmd.Flags().DurationVar(&policyDelay, "delay", 0, "wait after launching the app")
+	policyCmd.Flags().IntVar(&policyFlags.QuitOrder, "quit-order", 0, "apps quit by ascending quit order")
+	policyCmd.Flags().StringVar(&policyFlags.Quit, "quit-strategy", "", "graceful, term or kill once the app did not quit in time")
+	policyCmd.Flags().BoolVar(&policyFlags.Hide, "hide", false, "hide the app on switch instead of quitting it")
+	policyCmd.Flags().BoolVar(&clearFlag, "clear", false, "remove the policy of the app")
+	checkCmd.Flags().StringVarP(&snapName, "name", "n", "", "snapshot name")
+	remapCmd.Flags().StringVarP(&snapName, "name", "n", "", "snapshot name")
+	remapCmd.Flags().StringVar(&remapFrom, "from", "", "path prefix to rewrite")
+	remapCmd.Flags().StringVar(&remapTo, "to", "", "path prefix to rewrite it to")
+	hookCmd.Flags().StringVarP(&snapName, "name", "n", "", "snapshot name")
+	hookCmd.Flags().StringVar(&hookEvent, "event", "", "pre_take, post_take, pre_switch, post_switch or on_error")
+	hookCmd.Flags().StringVar(&hookFlags.Run, "run", "", "shell command to add to the hooks of the event")
+	hookCmd.Flags().DurationVar(&hookTimeout, "timeout", 0, "kill the hook after this long (default hook_timeout in config)")
+	hookCmd.Flags().StringVar(&hookFlags.OnFailure, "on-failure", "", "abort or continue the run when the hook fails")
+	hookCmd.Flags().BoolVar(&clearFlag, "clear", false, "remove the hooks of the event")
+	listSnapshotCmd.Flags().BoolVarP(&allFlag, "all", "a", false, "also list the hidden undo snapshots")
+	profileCopyCmd.Flags().StringVarP(&snapName, "name", "n", "", "snapshot name")
+	profileCopyCmd.Flags().StringVar(&dstProfile, "to", "", "destination profile")
+	profileCopyCmd.Flags().StringVar(&dstName, "as", "", "snapshot name in the destination profile")
+	profileCopyCmd.Flags().BoolVar(&moveFlag, "move", false, "remove the snapshot from the current profile after copying")
+	profileCmd.AddCommand(profileListCmd, profileCreateCmd, profileCopyCmd)
+	rootCmd.AddCommand(snapshotCmd, restoreCmd, listSnapshotCmd, rmSnapshotCmd, switchCmd, undoCmd, retryCmd, resumeCmd, checkCmd, remapCmd, policyCmd, hookCmd, profileCmd)
 }
 
 func main() {
-	if err := rootCmd.Execute(); err != nil {
+	// a cancelled switch stops before quitting any more apps, see `projsnap resume`
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
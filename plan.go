@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"projsnap/wm"
+	"sort"
+	"strings"
+)
+
+type PlanApp struct {
+	App     string   `json:"app"`
+	Args    []string `json:"args"`
+	Running bool     `json:"running,omitempty"`
+	Action  string   `json:"action,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+type PlanWindow struct {
+	App     string  `json:"app"`
+	Title   string  `json:"title"`
+	SpaceID int     `json:"space"`
+	Display int     `json:"display"`
+	Frame   wm.Rect `json:"frame"`
+}
+
+// ProjSnapPlan describes what take/restore/switch would do, nothing is launched
+// or quit while building it.
+type ProjSnapPlan struct {
+	Action   string       `json:"action"`
+	Snapshot string       `json:"snapshot"`
+	Capture  []PlanApp    `json:"capture,omitempty"`
+	Open     []PlanApp    `json:"open,omitempty"`
+	Quit     []string     `json:"quit,omitempty"`
+	Hide     []string     `json:"hide,omitempty"`
+	Windows  []PlanWindow `json:"windows,omitempty"`
+	Issues   []CheckIssue `json:"issues,omitempty"`
+}
+
+func sortedAppNames(appNames map[string]struct{}) []string {
+	names := make([]string, 0, len(appNames))
+	for app := range appNames {
+		names = append(names, app)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func planWindow(win *wm.WindowInfo) PlanWindow {
+	return PlanWindow{
+		App:     win.App,
+		Title:   win.Title,
+		SpaceID: win.SpaceID,
+		Display: win.DisplayID,
+		Frame:   win.Frame,
+	}
+}
+
+func (psm *ProjSnapMaster) planOpen(plan *ProjSnapPlan, appSnapshots []AppSnapshot, realRunning map[string]struct{}) {
+	for _, conf := range appSnapshots {
+		_, running := realRunning[conf.AppName]
+		plan.Open = append(plan.Open, PlanApp{App: conf.AppName, Args: conf.Args, Running: running})
+		if conf.WindowInfo != nil {
+			plan.Windows = append(plan.Windows, planWindow(conf.WindowInfo))
+		}
+	}
+}
+
+func (psm *ProjSnapMaster) PlanSave(ctx context.Context, snapName string) (*ProjSnapPlan, error) {
+	appNames, err := psm.getAllApplication(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := psm.wm.TakeSnapshot(ctx); err != nil {
+		return nil, err
+	}
+	plan := &ProjSnapPlan{Action: "take", Snapshot: snapName}
+	for _, app := range sortedAppNames(appNames) {
+		conf, err := psm.capture(ctx, app, true)
+		if err != nil {
+			plan.Capture = append(plan.Capture, PlanApp{App: app, Error: err.Error()})
+			continue
+		}
+		for i := range conf {
+			plan.Capture = append(plan.Capture, PlanApp{App: app, Args: conf[i].Args})
+			if wind, err := psm.wm.GetWindowInfo(ctx, app, psm.pidsOf(app)); err == nil {
+				plan.Windows = append(plan.Windows, planWindow(wind))
+			}
+		}
+	}
+	if psm.opt.quit {
+		plan.Quit = sortedAppNames(appNames)
+	}
+	return plan, nil
+}
+
+func (psm *ProjSnapMaster) PlanRestore(ctx context.Context, snapName string) (*ProjSnapPlan, error) {
+	appSnapshots, err := psm.loadRestoreSnapshot(snapName)
+	if err != nil {
+		return nil, err
+	}
+	plan := &ProjSnapPlan{Action: "restore", Snapshot: snapName}
+	psm.planOpen(plan, appSnapshots, map[string]struct{}{})
+	return plan, psm.planIssues(ctx, plan, appSnapshots)
+}
+
+func (psm *ProjSnapMaster) PlanSwitch(ctx context.Context, snapName string) (*ProjSnapPlan, error) {
+	appSnapshots, err := psm.loadRestoreSnapshot(snapName)
+	if err != nil {
+		return nil, err
+	}
+	realRunning, err := psm.getAllApplication(ctx)
+	if err != nil {
+		return nil, err
+	}
+	psm.policies = psm.meta.ManifestSnapshots[snapName].Policies
+	hidden := psm.hiddenApps()
+	plan := &ProjSnapPlan{Action: "switch", Snapshot: snapName}
+	for _, sw := range psm.planAppSwitch(ctx, appSnapshots, realRunning) {
+		args := make([]string, 0)
+		for _, conf := range sw.confs {
+			args = append(args, conf.Args...)
+			if conf.WindowInfo != nil {
+				plan.Windows = append(plan.Windows, planWindow(conf.WindowInfo))
+			}
+		}
+		if sw.action == switchPatch {
+			args = sw.missing
+		}
+		action := sw.action
+		if _, ok := hidden[sw.app]; ok && action != switchOpen {
+			action = switchUnhide
+		}
+		plan.Open = append(plan.Open, PlanApp{App: sw.app, Args: args, Running: sw.action != switchOpen, Action: action})
+	}
+	for _, app := range appsToQuit(appSnapshots, realRunning) {
+		if psm.hidesApp(psm.opt.hide, app) {
+			plan.Hide = append(plan.Hide, app)
+		} else {
+			plan.Quit = append(plan.Quit, app)
+		}
+	}
+	return plan, psm.planIssues(ctx, plan, appSnapshots)
+}
+
+func (psm *ProjSnapMaster) planIssues(ctx context.Context, plan *ProjSnapPlan, appSnapshots []AppSnapshot) error {
+	result, err := psm.check(ctx, plan.Snapshot, appSnapshots)
+	if err != nil {
+		return err
+	}
+	plan.Issues = result.Issues
+	return nil
+}
+
+func (p *ProjSnapPlan) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+func (p *ProjSnapPlan) Print(w io.Writer) {
+	fmt.Fprintf(w, "Plan for %s %s (dry run, nothing will be changed)\n", p.Action, p.Snapshot)
+	if len(p.Capture) > 0 {
+		fmt.Fprintln(w, "Capture:")
+		for _, app := range p.Capture {
+			if app.Error != "" {
+				fmt.Fprintf(w, "  %s\tfail: %s\n", app.App, app.Error)
+			} else {
+				fmt.Fprintf(w, "  %s\t%s\n", app.App, strings.Join(app.Args, " "))
+			}
+		}
+	}
+	if len(p.Open) > 0 {
+		fmt.Fprintln(w, "Open:")
+		for _, app := range p.Open {
+			state := ""
+			if app.Action != "" {
+				state = " (" + app.Action + ")"
+			} else if app.Running {
+				state = " (running)"
+			}
+			fmt.Fprintf(w, "  %s%s\t%s\n", app.App, state, strings.Join(app.Args, " "))
+		}
+	}
+	if len(p.Quit) > 0 {
+		fmt.Fprintln(w, "Quit:")
+		for _, app := range p.Quit {
+			fmt.Fprintf(w, "  %s\n", app)
+		}
+	}
+	if len(p.Hide) > 0 {
+		fmt.Fprintln(w, "Hide:")
+		for _, app := range p.Hide {
+			fmt.Fprintf(w, "  %s\n", app)
+		}
+	}
+	if len(p.Issues) > 0 {
+		fmt.Fprintln(w, "Invalid (preflight):")
+		for _, issue := range p.Issues {
+			fmt.Fprintf(w, "  [%s] %s\n", issue.Kind, issue)
+		}
+	}
+	if len(p.Windows) > 0 {
+		fmt.Fprintln(w, "Windows:")
+		for _, win := range p.Windows {
+			fmt.Fprintf(w, "  %s %q -> space %d, display %d, frame %v,%v %vx%v\n",
+				win.App, win.Title, win.SpaceID, win.Display, win.Frame.X, win.Frame.Y, win.Frame.W, win.Frame.H)
+		}
+	}
+}
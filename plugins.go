@@ -8,10 +8,22 @@ import (
 func LoadApplicationPlugins(ws *ProjSnapMaster) {
 	ws.RegisterApplication("Finder", apps.Finder{})
 	ws.RegisterApplication("Microsoft Edge", apps.Browser{})
+	ws.RegisterApplication("Google Chrome", apps.Browser{})
 	ws.RegisterApplication("draw.io", apps.DrawIO{})
 	ws.RegisterApplication("Obsidian", apps.Obsidian{})
 	ws.RegisterApplication("iterm2", apps.Iterm2{})
 	ws.RegisterApplication("goland", apps.JetBrains{})
+	ws.RegisterApplication("IntelliJ IDEA", apps.JetBrains{})
+
+	// bundle identifiers, matched before the localized display names above
+	ws.RegisterApplication("com.apple.finder", apps.Finder{})
+	ws.RegisterApplication("com.microsoft.edgemac", apps.Browser{})
+	ws.RegisterApplication("com.google.Chrome", apps.Browser{})
+	ws.RegisterApplication("com.jgraph.drawio.desktop", apps.DrawIO{})
+	ws.RegisterApplication("md.obsidian", apps.Obsidian{})
+	ws.RegisterApplication("com.googlecode.iterm2", apps.Iterm2{})
+	ws.RegisterApplication("com.jetbrains.goland", apps.JetBrains{})
+	ws.RegisterApplication("com.jetbrains.intellij", apps.JetBrains{})
 }
 
 func RemoveInWhiteList(appNames []string) []string {
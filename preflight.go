@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"projsnap/apps"
+	"projsnap/utils"
+	"strconv"
+	"strings"
+)
+
+const (
+	issueApp     = "app"
+	issuePath    = "path"
+	issueDisplay = "display"
+	issueSpace   = "space"
+)
+
+// CheckIssue is one item of a snapshot that can not be restored, Index points
+// into the snapshot.
+type CheckIssue struct {
+	Index int    `json:"index"`
+	App   string `json:"app"`
+	Kind  string `json:"kind"`
+	Item  string `json:"item"`
+}
+
+func (i CheckIssue) String() string {
+	switch i.Kind {
+	case issueApp:
+		return fmt.Sprintf("%s is not installed", i.App)
+	case issuePath:
+		return fmt.Sprintf("%s: %s does not exist", i.App, i.Item)
+	}
+	return fmt.Sprintf("%s: %s %s is not present", i.App, i.Kind, i.Item)
+}
+
+// CheckResult is the outcome of the preflight of a snapshot, it is returned as
+// the error when the snapshot has issues that were not skipped.
+type CheckResult struct {
+	Snapshot string       `json:"snapshot"`
+	Issues   []CheckIssue `json:"issues"`
+}
+
+func (r *CheckResult) Error() string {
+	issues := make([]string, 0, len(r.Issues))
+	for _, issue := range r.Issues {
+		issues = append(issues, issue.String())
+	}
+	return fmt.Sprintf("snapshot %s has %d invalid items (%s), rerun with --skip-invalid to skip them", r.Snapshot, len(r.Issues), strings.Join(issues, "; "))
+}
+
+func (r *CheckResult) Print(w io.Writer) {
+	if len(r.Issues) == 0 {
+		fmt.Fprintf(w, "%s: all apps, paths, displays and spaces are present\n", r.Snapshot)
+		return
+	}
+	fmt.Fprintf(w, "%s: %d invalid items\n", r.Snapshot, len(r.Issues))
+	for _, issue := range r.Issues {
+		fmt.Fprintf(w, "  [%s] %s\n", issue.Kind, issue)
+	}
+}
+
+// desktop is what the preflight checks a snapshot against, nil displays or
+// spaces are not checked.
+type desktop struct {
+	installed func(app string) bool
+	exists    func(path string) bool
+	displays  map[int]bool
+	spaces    map[int]bool
+}
+
+func (psm *ProjSnapMaster) findIssues(appSnapshots []AppSnapshot, d desktop) []CheckIssue {
+	issues := make([]CheckIssue, 0)
+	installed := make(map[string]bool)
+	for i, conf := range appSnapshots {
+		ok, checked := installed[conf.AppName]
+		if !checked {
+			ok = d.installed(conf.AppName)
+			installed[conf.AppName] = ok
+			if !ok {
+				issues = append(issues, CheckIssue{Index: i, App: conf.AppName, Kind: issueApp, Item: conf.AppName})
+			}
+		}
+		if !ok {
+			continue
+		}
+		if checker, ok := psm.GetPacker(conf.AppName).(apps.PathChecker); ok {
+			for _, arg := range conf.Args {
+				if path := checker.ArgPath(arg); path != "" && !d.exists(path) {
+					issues = append(issues, CheckIssue{Index: i, App: conf.AppName, Kind: issuePath, Item: arg})
+				}
+			}
+		}
+		if win := conf.WindowInfo; win != nil {
+			if d.displays != nil && win.DisplayID > 0 && !d.displays[win.DisplayID] {
+				issues = append(issues, CheckIssue{Index: i, App: conf.AppName, Kind: issueDisplay, Item: strconv.Itoa(win.DisplayID)})
+			} else if d.spaces != nil && win.SpaceID > 0 && !d.spaces[win.SpaceID] {
+				issues = append(issues, CheckIssue{Index: i, App: conf.AppName, Kind: issueSpace, Item: strconv.Itoa(win.SpaceID)})
+			}
+		}
+	}
+	return issues
+}
+
+// skipIssues leaves the invalid items out of appSnapshots: the entries of
+// missing apps and the entries whose every path is gone are dropped, missing
+// paths are removed from Args, and windows of missing displays or spaces are
+// not restored. The result keeps the indexes of appSnapshots.
+func skipIssues(appSnapshots []AppSnapshot, issues []CheckIssue) ([]AppSnapshot, map[int]bool) {
+	missingApps := make(map[string]bool)
+	badArgs := make(map[int]map[string]bool)
+	badWindows := make(map[int]bool)
+	for _, issue := range issues {
+		switch issue.Kind {
+		case issueApp:
+			missingApps[issue.App] = true
+		case issuePath:
+			if badArgs[issue.Index] == nil {
+				badArgs[issue.Index] = make(map[string]bool)
+			}
+			badArgs[issue.Index][issue.Item] = true
+		default:
+			badWindows[issue.Index] = true
+		}
+	}
+	result := make([]AppSnapshot, len(appSnapshots))
+	dropped := make(map[int]bool)
+	for i, conf := range appSnapshots {
+		result[i] = conf
+		if missingApps[conf.AppName] {
+			dropped[i] = true
+			continue
+		}
+		if bad := badArgs[i]; bad != nil {
+			c := *conf.AppConfig
+			c.Args = make(apps.PackConfig, 0, len(conf.Args))
+			for _, arg := range conf.Args {
+				if !bad[arg] {
+					c.Args = append(c.Args, arg)
+				}
+			}
+			if len(c.Args) == 0 {
+				dropped[i] = true
+			}
+			result[i].AppConfig = &c
+		}
+		if badWindows[i] {
+			result[i].WindowInfo = nil
+		}
+	}
+	return result, dropped
+}
+
+func (psm *ProjSnapMaster) check(ctx context.Context, snapName string, appSnapshots []AppSnapshot) (*CheckResult, error) {
+	displays, err := psm.wm.Displays(ctx)
+	if err != nil {
+		return nil, err
+	}
+	spaces, err := psm.wm.Spaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	d := desktop{
+		installed: func(app string) bool { return utils.AppInstalled(ctx, app) },
+		exists: func(path string) bool {
+			_, err := os.Stat(path)
+			return err == nil
+		},
+		displays: displays,
+		spaces:   spaces,
+	}
+	return &CheckResult{Snapshot: snapName, Issues: psm.findIssues(appSnapshots, d)}, nil
+}
+
+// CheckSnapshot verifies that the apps of snapName are installed, the paths
+// they open exist and the displays and spaces of their windows are present.
+func (psm *ProjSnapMaster) CheckSnapshot(ctx context.Context, snapName string) (*CheckResult, error) {
+	appSnapshots, err := psm.loadRestoreSnapshot(snapName)
+	if err != nil {
+		return nil, err
+	}
+	return psm.check(ctx, snapName, appSnapshots)
+}
+
+// preflight checks appSnapshots before any app is touched. Invalid items are
+// skipped with --skip-invalid or when the confirm option agrees, otherwise the
+// CheckResult is returned. dropped holds the indexes of the skipped entries.
+func (psm *ProjSnapMaster) preflight(ctx context.Context, snapName string, appSnapshots []AppSnapshot) (_ []AppSnapshot, dropped map[int]bool, err error) {
+	result, err := psm.check(ctx, snapName, appSnapshots)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(result.Issues) == 0 {
+		return appSnapshots, nil, nil
+	}
+	for _, issue := range result.Issues {
+		psm.emitError(issue.App, errors.New(issue.String()))
+	}
+	if !psm.opt.skipInvalid && (psm.opt.confirm == nil || !psm.opt.confirm(result)) {
+		return nil, nil, result
+	}
+	appSnapshots, dropped = skipIssues(appSnapshots, result.Issues)
+	return appSnapshots, dropped, nil
+}
+
+// skipSteps marks the unfinished steps of the dropped snapshot entries
+// skipped, and the window steps of the windows skipIssues left out.
+func skipSteps(j *RestoreJournal, appSnapshots []AppSnapshot, dropped map[int]bool) {
+	for i := range j.Apps {
+		if dropped[j.Apps[i].Index] && isUnfinishedStep(j.Apps[i]) {
+			j.Apps[i].Status, j.Apps[i].Error = stepSkipped, ""
+		}
+	}
+	for i := range j.Windows {
+		step := &j.Windows[i]
+		if (dropped[step.Index] || appSnapshots[step.Index].WindowInfo == nil) && isUnfinishedStep(*step) {
+			step.Status, step.Error = stepSkipped, ""
+		}
+	}
+}
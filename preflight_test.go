@@ -0,0 +1,64 @@
+package main
+
+import (
+	"projsnap/apps"
+	"projsnap/wm"
+	"reflect"
+	"testing"
+)
+
+func TestPreflightIssues(t *testing.T) {
+	psm := NewWorkspace(&ProjSnapOptions{})
+	appSnapshots := []AppSnapshot{
+		{AppConfig: &apps.AppConfig{AppName: "goland", Args: []string{"/work/api", "/work/gone"}}, WindowInfo: &wm.WindowInfo{App: "goland", DisplayID: 1, SpaceID: 2}},
+		{AppConfig: &apps.AppConfig{AppName: "Finder", Args: []string{"/work/moved"}}},
+		{AppConfig: &apps.AppConfig{AppName: "Obsidian", Args: []string{"/notes/.obsidian/workspace.json"}}, WindowInfo: &wm.WindowInfo{App: "Obsidian", DisplayID: 2, SpaceID: 5}},
+		{AppConfig: &apps.AppConfig{AppName: "Microsoft Edge", Args: []string{"https://example.com"}}},
+		{AppConfig: &apps.AppConfig{AppName: "Microsoft Edge", Args: []string{"https://go.dev"}}},
+	}
+	exists := map[string]bool{"/work/api": true, "/notes/.obsidian": true}
+	d := desktop{
+		installed: func(app string) bool { return app != "Microsoft Edge" },
+		exists:    func(path string) bool { return exists[path] },
+		displays:  map[int]bool{1: true},
+		spaces:    map[int]bool{1: true, 2: true},
+	}
+
+	issues := psm.findIssues(appSnapshots, d)
+	want := []CheckIssue{
+		{Index: 0, App: "goland", Kind: issuePath, Item: "/work/gone"},
+		{Index: 1, App: "Finder", Kind: issuePath, Item: "/work/moved"},
+		{Index: 2, App: "Obsidian", Kind: issueDisplay, Item: "2"},
+		{Index: 3, App: "Microsoft Edge", Kind: issueApp, Item: "Microsoft Edge"},
+	}
+	if !reflect.DeepEqual(issues, want) {
+		t.Fatalf("issues: %v, want %v", issues, want)
+	}
+
+	skipped, dropped := skipIssues(appSnapshots, issues)
+	if !reflect.DeepEqual(dropped, map[int]bool{1: true, 3: true, 4: true}) {
+		t.Fatalf("dropped: %v", dropped)
+	}
+	if got := skipped[0].Args; !reflect.DeepEqual(got, apps.PackConfig{"/work/api"}) {
+		t.Fatalf("goland args: %v", got)
+	}
+	if len(appSnapshots[0].Args) != 2 {
+		t.Fatal("skipIssues must not change the snapshot it was given")
+	}
+	if skipped[2].WindowInfo != nil {
+		t.Fatal("window on a missing display is not restored")
+	}
+
+	j := psm.newJournal(actionRestore, "proj", skipped, nil)
+	skipSteps(j, skipped, dropped)
+	for _, step := range j.Apps {
+		if dropped[step.Index] != (step.Status == stepSkipped) {
+			t.Fatalf("unexpected step: %+v", step)
+		}
+	}
+	for _, step := range j.Windows {
+		if step.Index == 2 || step.Status != stepPending {
+			t.Fatalf("unexpected window step: %+v", step)
+		}
+	}
+}
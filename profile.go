@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"projsnap/utils"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+const defaultProfile = "default"
+
+// baseDirs returns the root config and data directories shared by all profiles.
+// PROJSNAP_HOME wins over everything, linux follows the XDG base directory spec,
+// other platforms keep the historical ~/.projsnap layout.
+func baseDirs() (string, string, error) {
+	if home := os.Getenv("PROJSNAP_HOME"); home != "" {
+		dir, err := utils.ExpandUser(home)
+		return dir, dir, err
+	}
+	if runtime.GOOS != "linux" {
+		dir, err := utils.ExpandUser("~/.projsnap/")
+		return dir, dir, err
+	}
+	confHome := os.Getenv("XDG_CONFIG_HOME")
+	if confHome == "" {
+		confHome = "~/.config"
+	}
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = "~/.local/share"
+	}
+	confDir, err := utils.ExpandUser(filepath.Join(confHome, "projsnap"))
+	if err != nil {
+		return "", "", err
+	}
+	dataDir, err := utils.ExpandUser(filepath.Join(dataHome, "projsnap"))
+	return confDir, dataDir, err
+}
+
+func checkProfileName(profile string) error {
+	if profile == "" || profile == "." || profile == ".." ||
+		strings.HasPrefix(profile, ".") || strings.ContainsAny(profile, `/\`) {
+		return fmt.Errorf("invalid profile name: %q", profile)
+	}
+	return nil
+}
+
+// profileDirs returns the config and data directories of profile, the default
+// profile lives directly in the base directories.
+func profileDirs(profile string) (string, string, error) {
+	confDir, dataDir, err := baseDirs()
+	if err != nil {
+		return "", "", err
+	}
+	if profile == "" || profile == defaultProfile {
+		return confDir, dataDir, nil
+	}
+	if err := checkProfileName(profile); err != nil {
+		return "", "", err
+	}
+	return filepath.Join(confDir, "profiles", profile), filepath.Join(dataDir, "profiles", profile), nil
+}
+
+func listProfiles() ([]string, error) {
+	confDir, dataDir, err := baseDirs()
+	if err != nil {
+		return nil, err
+	}
+	found := map[string]struct{}{defaultProfile: {}}
+	for _, dir := range []string{confDir, dataDir} {
+		entries, err := os.ReadDir(filepath.Join(dir, "profiles"))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() && checkProfileName(entry.Name()) == nil {
+				found[entry.Name()] = struct{}{}
+			}
+		}
+	}
+	profiles := make([]string, 0, len(found))
+	for profile := range found {
+		profiles = append(profiles, profile)
+	}
+	sort.Strings(profiles)
+	return profiles, nil
+}
+
+func profileExists(profile string) (bool, error) {
+	if profile == "" || profile == defaultProfile {
+		return true, nil
+	}
+	profiles, err := listProfiles()
+	if err != nil {
+		return false, err
+	}
+	for _, p := range profiles {
+		if p == profile {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func createProfile(profile string) error {
+	if profile == defaultProfile {
+		return errors.New("the default profile always exists")
+	}
+	ok, err := profileExists(profile)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return fmt.Errorf("profile %s already exists", profile)
+	}
+	confDir, dataDir, err := profileDirs(profile)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(confDir, 0755); err != nil {
+		return err
+	}
+	return os.MkdirAll(dataDir, 0755)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestProfileDirs(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("PROJSNAP_HOME", home)
+
+	confDir, dataDir, err := profileDirs(defaultProfile)
+	if err != nil || confDir != home || dataDir != home {
+		t.Fatalf("default profile: %s %s %v", confDir, dataDir, err)
+	}
+	confDir, dataDir, err = profileDirs("work")
+	want := filepath.Join(home, "profiles", "work")
+	if err != nil || confDir != want || dataDir != want {
+		t.Fatalf("work profile: %s %s %v", confDir, dataDir, err)
+	}
+	if _, _, err := profileDirs("../evil"); err == nil {
+		t.Fatal("expect invalid profile name")
+	}
+
+	if err := createProfile("work"); err != nil {
+		t.Fatal(err)
+	}
+	if err := createProfile("work"); err == nil {
+		t.Fatal("expect duplicate profile error")
+	}
+	profiles, err := listProfiles()
+	if err != nil || len(profiles) != 2 || profiles[0] != defaultProfile || profiles[1] != "work" {
+		t.Fatalf("list profiles: %v %v", profiles, err)
+	}
+}
+
+func TestProfileDirsXDG(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("XDG directories are only used on linux")
+	}
+	t.Setenv("PROJSNAP_HOME", "")
+	t.Setenv("XDG_CONFIG_HOME", "/xdg/config")
+	t.Setenv("XDG_DATA_HOME", "/xdg/data")
+
+	confDir, dataDir, err := profileDirs("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if confDir != "/xdg/config/projsnap/profiles/work" || dataDir != "/xdg/data/projsnap/profiles/work" {
+		t.Fatalf("unexpected dirs: %s %s", confDir, dataDir)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	eventsText = "text"
+	eventsJSON = "json"
+)
+
+var openingVerbs = map[string]string{
+	switchOpen:     "Opening",
+	switchRelaunch: "Relaunching",
+	switchSkip:     "Skipping",
+	switchPatch:    "Patching",
+	switchUnhide:   "Unhiding",
+}
+
+// textProgress renders events as progress lines, packs only once finished.
+func textProgress(w io.Writer) Observer {
+	return ObserverFunc(func(e Event) {
+		count := ""
+		if e.Total > 0 {
+			count = fmt.Sprintf("[%d/%d] ", e.Done, e.Total)
+		}
+		fail := ""
+		if e.Error != "" {
+			fail = ": " + e.Error
+		}
+		switch e.Type {
+		case EventPhase:
+			if e.Phase == phaseDone && e.Error != "" {
+				fmt.Fprintf(w, "== %s %s failed%s\n", e.Action, e.Snapshot, fail)
+			} else {
+				fmt.Fprintf(w, "== %s %s: %s\n", e.Action, e.Snapshot, e.Phase)
+			}
+		case EventPackFinished:
+			if e.Error != "" {
+				fmt.Fprintf(w, "%sPack %s failed%s\n", count, e.App, fail)
+			} else {
+				fmt.Fprintf(w, "%sPacked %s\n", count, e.App)
+			}
+		case EventAppOpening:
+			fmt.Fprintln(w, strings.TrimSpace(fmt.Sprintf("%s %s %s", openingVerbs[e.Status], e.App, strings.Join(e.Args, " "))))
+		case EventAppWaiting:
+			fmt.Fprintf(w, "Waiting %s after launching %s\n", time.Duration(e.Delay), e.App)
+		case EventAppOpened, EventAppQuit:
+			fmt.Fprintf(w, "%s%s %s%s\n", count, e.App, e.Status, fail)
+		case EventWindowRestored:
+			fmt.Fprintf(w, "%sWindow of %s %s%s\n", count, e.App, e.Status, fail)
+		case EventError:
+			if e.App == "" {
+				fmt.Fprintf(w, "error: %s\n", e.Error)
+			} else {
+				fmt.Fprintf(w, "%s: %s\n", e.App, e.Error)
+			}
+		}
+	})
+}
+
+// jsonProgress writes every event as one line of JSON.
+func jsonProgress(w io.Writer) Observer {
+	enc := json.NewEncoder(w)
+	return ObserverFunc(func(e Event) {
+		_ = enc.Encode(e)
+	})
+}
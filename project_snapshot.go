@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/boltdb/bolt"
@@ -9,8 +10,10 @@ import (
 	"path/filepath"
 	"projsnap/apps"
 	"projsnap/utils"
+	"projsnap/wm"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -21,13 +24,17 @@ var (
 
 type AppSnapshot struct {
 	*apps.AppConfig
-	*WindowInfo
+	*wm.WindowInfo
 }
 
 type ProjSnapManifest struct {
 	SnapshotName string `json:"snapshot_name"`
 	SnapshotKey  string `json:"snapshot_key"`
 	Ctime        int64  `json:"ctime"`
+	Hidden       bool   `json:"hidden,omitempty"`
+
+	Policies map[string]AppPolicy `json:"policies,omitempty"`
+	Hooks    map[string][]Hook    `json:"hooks,omitempty"`
 }
 
 type ProjSnapMeta struct {
@@ -36,16 +43,39 @@ type ProjSnapMeta struct {
 
 type ProjSnapOptions struct {
 	quit      bool
+	noUndo    bool
+	hide      bool
+	force     bool
+	workers   int
+	profile   string
 	configDir string
+	dataDir   string
+	remap     []RemapRule
+	aliases   map[string]string
+
+	// skipInvalid skips what the preflight found invalid, otherwise confirm
+	// is asked when set
+	skipInvalid bool
+	confirm     func(*CheckResult) bool
 }
 
 type ProjSnapMaster struct {
 	specPackers   map[string]apps.AppPacker
 	generalPacker apps.AppPacker
 	opt           *ProjSnapOptions
+	conf          *ProjSnapConfig
 	meta          *ProjSnapMeta
 	db            *bolt.DB
-	wm            *WindowManager
+	wm            *wm.Manager
+	policies      map[string]AppPolicy
+	identities    map[string]*apps.AppIdentity
+	mu            sync.Mutex
+
+	run       hookRun
+	observers map[int]Observer
+	obsSeq    int
+	obsMu     sync.Mutex
+	notifyMu  sync.Mutex
 }
 
 func NewWorkspace(opt *ProjSnapOptions) *ProjSnapMaster {
@@ -54,7 +84,8 @@ func NewWorkspace(opt *ProjSnapOptions) *ProjSnapMaster {
 		generalPacker: apps.NormalPacker{},
 		opt:           opt,
 		meta:          &ProjSnapMeta{ManifestSnapshots: make(map[string]ProjSnapManifest)},
-		wm:            NewWindowManager(),
+		// Open replaces it by the window manager of the config
+		wm: wm.NewManager(wm.None{}),
 	}
 	LoadApplicationPlugins(psm)
 	return psm
@@ -67,18 +98,33 @@ func (psm *ProjSnapMaster) Close() error {
 	return nil
 }
 
+// Open loads the config and opens the snapshot database. The window manager
+// of the config is checked once a command touches windows.
 func (psm *ProjSnapMaster) Open() (err error) {
-	if _, err := os.Stat(psm.opt.configDir); os.IsNotExist(err) {
-		if err = os.MkdirAll(psm.opt.configDir, 0755); err != nil {
-			return err
+	if psm.opt.dataDir == "" {
+		psm.opt.dataDir = psm.opt.configDir
+	}
+	for _, dir := range []string{psm.opt.configDir, psm.opt.dataDir} {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			if err = os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
 		}
 	}
+	if psm.conf, err = loadConfig(psm.opt.configDir); err != nil {
+		return fmt.Errorf("load config fail, err: %v", err)
+	}
+	backend, err := wm.New(psm.conf.WindowManager)
+	if err != nil {
+		return err
+	}
+	psm.wm = wm.NewManager(backend)
 
 	// open db
-	dbPath := filepath.Join(psm.opt.configDir, "projsnap.db")
+	dbPath := filepath.Join(psm.opt.dataDir, "projsnap.db")
 	db, err := bolt.Open(dbPath, 0600, nil)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("open db %s fail, err: %v", dbPath, err)
 	}
 	psm.db = db
 
@@ -86,15 +132,13 @@ func (psm *ProjSnapMaster) Open() (err error) {
 	if err = psm.db.Update(func(tx *bolt.Tx) error {
 		_, _ = tx.CreateBucketIfNotExists(manifestBucketName)
 		_, _ = tx.CreateBucketIfNotExists(SnapshotsBucketName)
+		_, _ = tx.CreateBucketIfNotExists(journalBucketName)
+		_, _ = tx.CreateBucketIfNotExists(hiddenBucketName)
 		return nil
 	}); err != nil {
 		return err
 	}
-	if err = psm.loadManifest(); err != nil {
-		return err
-	}
-	// check yabai
-	return psm.wm.PreCheck()
+	return psm.loadManifest()
 }
 
 func (psm *ProjSnapMaster) RemoveSnapshots(snapName string) error {
@@ -108,12 +152,33 @@ func (psm *ProjSnapMaster) RemoveSnapshots(snapName string) error {
 
 		snap := tx.Bucket(SnapshotsBucketName)
 		_ = snap.Delete([]byte(snapshot.SnapshotKey))
+		delete(psm.meta.ManifestSnapshots, snapName)
 		return nil
 	})
 }
 
-func (psm *ProjSnapMaster) ListSnapshots() map[string]ProjSnapManifest {
-	return psm.meta.ManifestSnapshots
+func (psm *ProjSnapMaster) CopySnapshot(snapName string, dst *ProjSnapMaster, dstName string) error {
+	if _, ok := psm.meta.ManifestSnapshots[snapName]; !ok {
+		return fmt.Errorf("no found snapName: %s", snapName)
+	}
+	appSnapshots, err := psm.loadSnapshot(snapName)
+	if err != nil {
+		return err
+	}
+	_, err = dst.dumpProjSnapshot(dstName, appSnapshots, false)
+	return err
+}
+
+// ListSnapshots returns the user snapshots, all also includes the hidden undo
+// snapshots.
+func (psm *ProjSnapMaster) ListSnapshots(all bool) map[string]ProjSnapManifest {
+	result := make(map[string]ProjSnapManifest)
+	for name, snapshot := range psm.meta.ManifestSnapshots {
+		if all || !snapshot.Hidden {
+			result[name] = snapshot
+		}
+	}
+	return result
 }
 
 func (psm *ProjSnapMaster) loadManifest() error {
@@ -131,7 +196,22 @@ func (psm *ProjSnapMaster) loadManifest() error {
 	})
 }
 
-func (psm *ProjSnapMaster) dumpProjSnapshot(snapName string, appSnapshots []AppSnapshot) (seq uint64, err error) {
+// putManifest updates the manifest of an existing snapshot, its data is left.
+func (psm *ProjSnapMaster) putManifest(snapshot ProjSnapManifest) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	if err := psm.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(manifestBucketName).Put([]byte(snapshot.SnapshotName), data)
+	}); err != nil {
+		return err
+	}
+	psm.meta.ManifestSnapshots[snapshot.SnapshotName] = snapshot
+	return nil
+}
+
+func (psm *ProjSnapMaster) dumpProjSnapshot(snapName string, appSnapshots []AppSnapshot, hidden bool) (seq uint64, err error) {
 	oldSnap, _ := psm.meta.ManifestSnapshots[snapName]
 	err = psm.db.Update(func(tx *bolt.Tx) error {
 		snap := tx.Bucket(SnapshotsBucketName)
@@ -156,12 +236,19 @@ func (psm *ProjSnapMaster) dumpProjSnapshot(snapName string, appSnapshots []AppS
 			SnapshotName: snapName,
 			SnapshotKey:  curSnapID,
 			Ctime:        time.Now().Unix(),
+			Hidden:       hidden,
+			Policies:     oldSnap.Policies,
+			Hooks:        oldSnap.Hooks,
 		}
 		ssData, err := json.Marshal(ps)
 		if err != nil {
 			return err
 		}
-		return manifest.Put([]byte(snapName), ssData)
+		if err := manifest.Put([]byte(snapName), ssData); err != nil {
+			return err
+		}
+		psm.meta.ManifestSnapshots[snapName] = ps
+		return nil
 	})
 	return
 }
@@ -170,158 +257,354 @@ func (psm *ProjSnapMaster) RegisterApplication(name string, app apps.AppPacker)
 	psm.specPackers[strings.ToLower(name)] = app
 }
 
+// GetPacker returns the packer of a running app by its bundle identifier, and
+// falls back to the display name.
 func (psm *ProjSnapMaster) GetPacker(appName string) apps.AppPacker {
-	appName = strings.ToLower(appName)
-	if packer, ok := psm.specPackers[appName]; ok {
+	return psm.packerOf(appName, psm.identities[appName])
+}
+
+// packerFor returns the packer of a snapshot entry, by the identity it was
+// captured with. Old snapshots have none and go by display name.
+func (psm *ProjSnapMaster) packerFor(conf *apps.AppConfig) apps.AppPacker {
+	if conf.Identity == nil {
+		return psm.GetPacker(conf.AppName)
+	}
+	return psm.packerOf(conf.AppName, conf.Identity)
+}
+
+func (psm *ProjSnapMaster) packerOf(appName string, identity *apps.AppIdentity) apps.AppPacker {
+	if identity != nil {
+		for _, id := range []string{identity.BundleID, identity.DesktopID} {
+			if packer, ok := psm.specPackers[strings.ToLower(id)]; ok && id != "" {
+				return packer
+			}
+		}
+	}
+	// the packers known by display name script macOS apps, an app with a
+	// desktop file ID runs on linux
+	if identity != nil && identity.DesktopID != "" {
+		return psm.generalPacker
+	}
+	if packer, ok := psm.specPackers[strings.ToLower(appName)]; ok {
 		return packer
 	}
 	return psm.generalPacker
 }
 
-func (psm *ProjSnapMaster) quitAllApplication(appNames map[string]struct{}) {
-	hasTerm := false
-	for app := range appNames {
-		if app != "iTerm2" {
-			log.Printf("quit %s, err: %v", app, psm.GetPacker(app).Quit(app))
+// pidsOf returns the pids of a running app as getAllApplication saw them.
+func (psm *ProjSnapMaster) pidsOf(appName string) []int {
+	if identity := psm.identities[appName]; identity != nil {
+		return identity.PIDs
+	}
+	return nil
+}
+
+// appContext bounds a single pack, unpack or quit of app by its timeout.
+func (psm *ProjSnapMaster) appContext(ctx context.Context, app string) (context.Context, context.CancelFunc) {
+	timeout := psm.conf.timeoutOf(app)
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// capture packs the state of app, preview avoids packers with side effects.
+func (psm *ProjSnapMaster) capture(ctx context.Context, app string, preview bool) ([]apps.AppConfig, error) {
+	ctx, cancel := psm.appContext(ctx, app)
+	defer cancel()
+	packer := psm.GetPacker(app)
+	if previewer, ok := packer.(apps.Previewer); ok && preview {
+		return previewer.Preview(ctx, psm.opt.configDir, app)
+	}
+	return packer.Pack(ctx, psm.opt.configDir, app)
+}
+
+func (psm *ProjSnapMaster) unpack(ctx context.Context, conf *apps.AppConfig, running bool) error {
+	ctx, cancel := psm.appContext(ctx, conf.AppName)
+	defer cancel()
+	return psm.packerFor(conf).Unpack(ctx, conf, running)
+}
+
+func (psm *ProjSnapMaster) patch(ctx context.Context, conf *apps.AppConfig, missing []string) error {
+	ctx, cancel := psm.appContext(ctx, conf.AppName)
+	defer cancel()
+	return psm.GetPacker(conf.AppName).(apps.AppPatcher).Patch(ctx, conf, missing)
+}
+
+func (psm *ProjSnapMaster) isOrdered(app string) bool {
+	ordered, ok := psm.GetPacker(app).(apps.OrderedPacker)
+	return ok && ordered.Ordered()
+}
+
+// runApps calls fn for every app of appNames, independent apps run on the
+// worker pool and ordered apps one by one afterwards, in their given order.
+func (psm *ProjSnapMaster) runApps(ctx context.Context, appNames []string, fn func(ctx context.Context, i int)) {
+	workers := psm.opt.workers
+	if workers <= 0 {
+		workers = psm.conf.Workers
+	}
+	parallel, ordered := make([]int, 0), make([]int, 0)
+	for i, app := range appNames {
+		if psm.isOrdered(app) {
+			ordered = append(ordered, i)
 		} else {
-			hasTerm = true
+			parallel = append(parallel, i)
 		}
 	}
-	// todo: hard code
-	if hasTerm {
-		_ = psm.GetPacker("iTerm2").Quit("iTerm2")
+	utils.ForEach(ctx, workers, len(parallel), func(ctx context.Context, k int) {
+		fn(ctx, parallel[k])
+	})
+	for _, i := range ordered {
+		if ctx.Err() != nil {
+			return
+		}
+		fn(ctx, i)
 	}
 }
 
-func (psm *ProjSnapMaster) SaveSnapshot(snapName string) (bool, error) {
-	appNames, err := psm.getAllApplication()
+func (psm *ProjSnapMaster) quitAllApplication(ctx context.Context, appNames map[string]struct{}) error {
+	names := sortedAppNames(appNames)
+	waves, err := psm.quitWaves(names)
 	if err != nil {
-		return false, err
+		return err
 	}
-	if err := psm.wm.TakeSnapshot(); err != nil {
-		return false, err
+	psm.emitPhase(phaseQuit)
+	quits := &counter{total: len(names)}
+	psm.runWaves(ctx, names, waves, func(ctx context.Context, i int) {
+		event := Event{Type: EventAppQuit, App: names[i], Status: stepQuit}
+		if err := psm.quitApp(ctx, names[i]); err != nil {
+			event.Status, event.Error = stepFailed, err.Error()
+		}
+		event.Done, event.Total = quits.next()
+		psm.emit(event)
+	})
+	return nil
+}
+
+// collectAppSnapshots packs every app with its window, the window manager must
+// hold a fresh TakeSnapshot. A preview capture is best effort and skips the apps
+// that fail.
+func (psm *ProjSnapMaster) collectAppSnapshots(ctx context.Context, appNames map[string]struct{}, preview bool) ([]AppSnapshot, error) {
+	names := sortedAppNames(appNames)
+	confs := make([][]apps.AppConfig, len(names))
+	errs := make([]error, len(names))
+	packs := &counter{total: len(names)}
+	psm.runApps(ctx, names, func(ctx context.Context, i int) {
+		psm.emit(Event{Type: EventPackStarted, App: names[i]})
+		confs[i], errs[i] = psm.capture(ctx, names[i], preview)
+		event := Event{Type: EventPackFinished, App: names[i]}
+		if errs[i] != nil {
+			event.Error = errs[i].Error()
+		}
+		event.Done, event.Total = packs.next()
+		psm.emit(event)
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
+	// assemble in app name order, the snapshot does not depend on who packed first
 	appSnapshots := make([]AppSnapshot, 0)
-	for app := range appNames {
-		conf, err := psm.GetPacker(app).Pack(psm.opt.configDir, app)
+	for k, app := range names {
+		conf, err := confs[k], errs[k]
+		if err != nil && preview {
+			// already told by the pack_finished event
+			continue
+		}
 		if err != nil {
-			return false, fmt.Errorf("%s occur fail, err: %v", app, err)
+			return nil, fmt.Errorf("%s occur fail, err: %v", app, err)
 		}
 		// todo: save要关联正常，restore关联也要正常，现在是随机
+		identity := psm.identities[app]
 		for i := range conf {
-			wind, _ := psm.wm.GetWindowInfo(app) // ignore error
+			if identity != nil {
+				conf[i].Identity = &apps.AppIdentity{BundleID: identity.BundleID, DesktopID: identity.DesktopID, ExecPath: identity.ExecPath}
+			}
+			wind, _ := psm.wm.GetWindowInfo(ctx, app, psm.pidsOf(app)) // ignore error
 			appSnapshots = append(appSnapshots, AppSnapshot{AppConfig: &conf[i], WindowInfo: wind})
 		}
 	}
+	return appSnapshots, nil
+}
+
+// SaveSnapshot takes snapshot snapName between the pre_take and post_take hooks.
+func (psm *ProjSnapMaster) SaveSnapshot(ctx context.Context, snapName string) (bool, error) {
+	saved := false
+	_, err := psm.withHooks(ctx, hookPreTake, hookPostTake, hookRun{action: "take", snapshot: snapName}, func() (*RestoreReport, error) {
+		var err error
+		saved, err = psm.saveSnapshot(ctx, snapName)
+		return nil, err
+	})
+	return saved, err
+}
+
+func (psm *ProjSnapMaster) saveSnapshot(ctx context.Context, snapName string) (bool, error) {
+	start := time.Now()
+	psm.emitPhase(phaseCapture)
+	appNames, err := psm.getAllApplication(ctx)
+	if err != nil {
+		return false, err
+	}
+	if psm.opt.quit {
+		if err := psm.checkForceQuit(sortedAppNames(appNames), false); err != nil {
+			return false, err
+		}
+	}
+	if err := psm.wm.TakeSnapshot(ctx); err != nil {
+		return false, err
+	}
 
-	ctxID, err := psm.dumpProjSnapshot(snapName, appSnapshots)
+	appSnapshots, err := psm.collectAppSnapshots(ctx, appNames, false)
 	if err != nil {
-		log.Fatal(err)
+		return false, err
 	}
-	log.Printf("SaveWorkSpace Success, ctxID: %d, alias: %s", ctxID, snapName)
+
+	ctxID, err := psm.dumpProjSnapshot(snapName, appSnapshots, false)
+	if err != nil {
+		return false, err
+	}
+	log.Printf("SaveWorkSpace Success, ctxID: %d, alias: %s, took: %s", ctxID, snapName, time.Since(start).Round(time.Millisecond))
 
 	if psm.opt.quit {
-		psm.quitAllApplication(appNames)
+		if err := psm.quitAllApplication(ctx, appNames); err != nil {
+			return true, err
+		}
 	}
 	return true, nil
 }
 
-func (psm *ProjSnapMaster) loadSnapshot(snapName string) []AppSnapshot {
+func (psm *ProjSnapMaster) loadSnapshot(snapName string) ([]AppSnapshot, error) {
 	// alias
 	snapshot, ok := psm.meta.ManifestSnapshots[snapName]
 	if !ok {
-		log.Fatal("no found snapName")
+		return nil, fmt.Errorf("no found snapName: %s", snapName)
 	}
 	appSnapshots := make([]AppSnapshot, 0)
 
-	_ = psm.db.View(func(tx *bolt.Tx) error {
+	err := psm.db.View(func(tx *bolt.Tx) error {
 		snap := tx.Bucket(SnapshotsBucketName)
 		data := snap.Get([]byte(snapshot.SnapshotKey))
+		if data == nil {
+			return fmt.Errorf("snapshot %s lost its data", snapName)
+		}
 		return json.Unmarshal(data, &appSnapshots)
 	})
-	return appSnapshots
+	return appSnapshots, err
 }
 
-func (psm *ProjSnapMaster) openAppFromSnapshot(appSnapshots []AppSnapshot, realRunning map[string]struct{}) error {
-	for i, conf := range appSnapshots {
-		log.Printf("[%d/%d] Opening %s, args: %v\n", i+1, len(appSnapshots), conf.AppName, conf.Args)
-		_, running := realRunning[conf.AppName]
-		if err := psm.GetPacker(conf.AppName).Unpack(conf.AppConfig, running); err != nil {
-			return err
-		}
+// openAppFromSnapshot unpacks every app and keeps going past failures, done
+// is told the outcome of each snapshot entry. Apps open concurrently in the
+// waves of their lifecycle policies, the entries of one app in their snapshot
+// order. Only a dependency cycle is returned.
+func (psm *ProjSnapMaster) openAppFromSnapshot(ctx context.Context, appSnapshots []AppSnapshot, realRunning map[string]struct{}, done func(int, error)) error {
+	groups := groupAppSnapshots(appSnapshots)
+	names := make([]string, 0, len(groups))
+	for _, group := range groups {
+		names = append(names, group.app)
 	}
-	return nil
-}
-
-func (psm *ProjSnapMaster) SwitchSnapshot(snapName string) error {
-	appSnapshots := psm.loadSnapshot(snapName)
-	realRunning, err := psm.getAllApplication()
+	waves, err := psm.openWaves(names)
 	if err != nil {
 		return err
 	}
-	// open all
-	if err := psm.openAppFromSnapshot(appSnapshots, realRunning); err != nil {
-		return err
+	psm.runWaves(ctx, names, waves, func(ctx context.Context, i int) {
+		_, running := realRunning[groups[i].app]
+		psm.openApp(ctx, groups[i], running, done)
+	})
+	return nil
+}
+
+// openApp unpacks the snapshot entries of one app in order.
+func (psm *ProjSnapMaster) openApp(ctx context.Context, group *appGroup, running bool, done func(int, error)) {
+	action := switchOpen
+	if running {
+		action = switchRelaunch
 	}
-	// close other app
-	for app := range realRunning {
-		shouldClose := true
-		for _, conf := range appSnapshots {
-			if conf.AppName == app {
-				shouldClose = false
-				break
-			}
-		}
-		if shouldClose {
-			log.Printf("Closing %s\n", app)
-			_ = psm.GetPacker(app).Quit(app)
+	for k, conf := range group.confs {
+		if ctx.Err() != nil {
+			return
 		}
+		psm.emit(Event{Type: EventAppOpening, App: conf.AppName, Args: conf.Args, Status: action})
+		done(group.indexes[k], psm.unpack(ctx, conf.AppConfig, running))
 	}
-	// wait
-	time.Sleep(3 * time.Second)
-	// get current opened windows
-	if err := psm.wm.TakeSnapshot(); err != nil {
-		return err
-	}
-	// restore windows
-	for _, conf := range appSnapshots {
-		_ = psm.wm.RestoreWindow(conf.WindowInfo)
-	}
-	return nil
+	psm.launched(ctx, group.app)
 }
 
-func (psm *ProjSnapMaster) RestoreSnapshot(snapName string) error {
-	appSnapshots := psm.loadSnapshot(snapName)
-	// open app, ignore current whether is opened
-	if err := psm.openAppFromSnapshot(appSnapshots, map[string]struct{}{}); err != nil {
-		return err
+func appsToQuit(appSnapshots []AppSnapshot, realRunning map[string]struct{}) []string {
+	keep := make(map[string]struct{})
+	for _, conf := range appSnapshots {
+		keep[conf.AppName] = struct{}{}
 	}
-	// wait app
-	time.Sleep(3 * time.Second)
-	// get current opened windows
-	if err := psm.wm.TakeSnapshot(); err != nil {
-		return err
+	quit := make([]string, 0)
+	for _, app := range sortedAppNames(realRunning) {
+		if _, ok := keep[app]; !ok {
+			quit = append(quit, app)
+		}
 	}
-	// restore windows
-	for _, conf := range appSnapshots {
-		_ = psm.wm.RestoreWindow(conf.WindowInfo)
+	return quit
+}
+
+func (psm *ProjSnapMaster) SwitchSnapshot(ctx context.Context, snapName string) (*RestoreReport, error) {
+	appSnapshots, err := psm.loadRestoreSnapshot(snapName)
+	if err != nil {
+		return nil, err
 	}
+	return psm.withHooks(ctx, hookPreSwitch, hookPostSwitch, hookRun{action: actionSwitch, snapshot: snapName}, func() (*RestoreReport, error) {
+		appSnapshots, dropped, err := psm.preflight(ctx, snapName, appSnapshots)
+		if err != nil {
+			return nil, err
+		}
+		if err := psm.saveUndo(ctx); err != nil {
+			return nil, err
+		}
+		realRunning, err := psm.getAllApplication(ctx)
+		if err != nil {
+			return nil, err
+		}
+		j := psm.newJournal(actionSwitch, snapName, appSnapshots, appsToQuit(appSnapshots, realRunning))
+		skipSteps(j, appSnapshots, dropped)
+		return psm.runJournal(ctx, j, appSnapshots, isPendingStep)
+	})
+}
 
-	return nil
+// RestoreSnapshot runs between the pre_switch and post_switch hooks as well,
+// PROJSNAP_ACTION tells them apart.
+func (psm *ProjSnapMaster) RestoreSnapshot(ctx context.Context, snapName string) (*RestoreReport, error) {
+	appSnapshots, err := psm.loadRestoreSnapshot(snapName)
+	if err != nil {
+		return nil, err
+	}
+	return psm.withHooks(ctx, hookPreSwitch, hookPostSwitch, hookRun{action: actionRestore, snapshot: snapName}, func() (*RestoreReport, error) {
+		appSnapshots, dropped, err := psm.preflight(ctx, snapName, appSnapshots)
+		if err != nil {
+			return nil, err
+		}
+		if err := psm.saveUndo(ctx); err != nil {
+			return nil, err
+		}
+		j := psm.newJournal(actionRestore, snapName, appSnapshots, nil)
+		skipSteps(j, appSnapshots, dropped)
+		return psm.runJournal(ctx, j, appSnapshots, isPendingStep)
+	})
 }
 
-func (psm *ProjSnapMaster) getAllApplication() (map[string]struct{}, error) {
-	allApp, err := utils.RunOsascript(`
-	tell application "System Events"
-		get name of (processes where background only is false)
-	end tell`)
+// getAllApplication lists the running apps by display name and remembers the
+// identity of each, for packer lookup and window matching.
+func (psm *ProjSnapMaster) getAllApplication(ctx context.Context) (map[string]struct{}, error) {
+	allApp, err := utils.RunningApps(ctx)
 	if err != nil {
 		return nil, err
 	}
 	result := make(map[string]struct{})
+	identities := make(map[string]*apps.AppIdentity)
 	for _, app := range allApp {
-		result[app] = struct{}{}
+		result[app.Name] = struct{}{}
+		identity, ok := identities[app.Name]
+		if !ok {
+			identity = &apps.AppIdentity{BundleID: app.BundleID, DesktopID: app.DesktopID, ExecPath: app.Path}
+			identities[app.Name] = identity
+		}
+		identity.PIDs = append(identity.PIDs, app.PID)
 	}
+	psm.identities = identities
 	return result, nil
 }
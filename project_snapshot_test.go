@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/boltdb/bolt"
 	"log"
+	"projsnap/apps"
 	"testing"
 )
 
@@ -16,7 +18,7 @@ func TestNewWorkspacePack(t *testing.T) {
 	}
 	defer ws.Close()
 
-	_, _ = ws.GetPacker("Microsoft Edge").Pack("", "Microsoft Edge")
+	_, _ = ws.GetPacker("Microsoft Edge").Pack(context.Background(), "", "Microsoft Edge")
 }
 
 func TestNewWorkspaceUnPack(t *testing.T) {
@@ -29,10 +31,10 @@ func TestNewWorkspaceUnPack(t *testing.T) {
 	defer ws.Close()
 
 	appName := "Microsoft Edge"
-	appSnapshots := ws.loadSnapshot("fuck")
+	appSnapshots, _ := ws.loadSnapshot("fuck")
 	for _, sshot := range appSnapshots {
 		if sshot.AppName == appName {
-			_ = ws.GetPacker(appName).Unpack(sshot.AppConfig, true)
+			_ = ws.GetPacker(appName).Unpack(context.Background(), sshot.AppConfig, true)
 		}
 	}
 }
@@ -59,3 +61,15 @@ func TestNewWorkspacelistAll(t *testing.T) {
 		return nil
 	})
 }
+
+func TestAppsToQuit(t *testing.T) {
+	appSnapshots := []AppSnapshot{
+		{AppConfig: &apps.AppConfig{AppName: "Finder"}},
+		{AppConfig: &apps.AppConfig{AppName: "goland"}},
+	}
+	running := map[string]struct{}{"Slack": {}, "Finder": {}, "Mail": {}}
+	quit := appsToQuit(appSnapshots, running)
+	if len(quit) != 2 || quit[0] != "Mail" || quit[1] != "Slack" {
+		t.Fatalf("unexpected quit list: %v", quit)
+	}
+}
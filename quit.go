@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"projsnap/apps"
+	"projsnap/utils"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	quitGraceful = "graceful"
+	quitTerm     = "term"
+	quitKill     = "kill"
+)
+
+const (
+	// termGrace is how long an app gets to exit after SIGTERM before SIGKILL
+	termGrace        = 5 * time.Second
+	quitPollInterval = 200 * time.Millisecond
+)
+
+// QuitError is returned for an app that is still running or still has windows
+// open after it was asked to quit.
+type QuitError struct {
+	App     string
+	PIDs    []int
+	Windows []string
+}
+
+func (e *QuitError) Error() string {
+	msg := fmt.Sprintf("%s refused to quit", e.App)
+	if len(e.PIDs) > 0 {
+		msg += fmt.Sprintf(", pids %v still running", e.PIDs)
+	}
+	if len(e.Windows) > 0 {
+		msg += fmt.Sprintf(", %d windows open: %s", len(e.Windows), strings.Join(e.Windows, ", "))
+	}
+	return msg
+}
+
+func (psm *ProjSnapMaster) quitStrategyOf(app string) string {
+	if strategy := psm.policyOf(app).Quit; strategy != "" {
+		return strategy
+	}
+	return quitGraceful
+}
+
+func isForceQuit(strategy string) bool {
+	return strategy == quitTerm || strategy == quitKill
+}
+
+// checkForceQuit refuses to quit apps whose strategy may signal them, unless
+// --force is given. Apps that are hidden instead are not quit at all.
+func (psm *ProjSnapMaster) checkForceQuit(appNames []string, hide bool) error {
+	forced := make([]string, 0)
+	for _, app := range appNames {
+		if psm.hidesApp(hide, app) {
+			continue
+		}
+		switch strategy := psm.quitStrategyOf(app); {
+		case isForceQuit(strategy):
+			forced = append(forced, app)
+		case strategy != quitGraceful:
+			return fmt.Errorf("unknown quit strategy of %s: %s", app, strategy)
+		}
+	}
+	if len(forced) > 0 && !psm.opt.force {
+		return fmt.Errorf("%s may be force quit, rerun with --force", strings.Join(forced, ", "))
+	}
+	return nil
+}
+
+// quitApp asks app to quit and confirms it exited, or closed its windows for
+// packers that only close them. An app still up after quit_timeout is sent
+// SIGTERM, and SIGKILL after termGrace, when its strategy says so and --force
+// is given, otherwise a QuitError tells what is left.
+func (psm *ProjSnapMaster) quitApp(ctx context.Context, app string) error {
+	ctx, cancel := psm.appContext(ctx, app)
+	defer cancel()
+	packer := psm.GetPacker(app)
+	pids, err := utils.GetAppPIDs(ctx, app)
+	if err != nil {
+		log.Printf("get pids of %s fail, err: %v", app, err)
+	}
+
+	// an app blocked on a "save changes?" dialog may not answer the quit at all
+	deadline := time.Now().Add(time.Duration(psm.conf.QuitTimeout))
+	quitCtx, quitCancel := context.WithDeadline(ctx, deadline)
+	err = packer.Quit(quitCtx, app)
+	quitCancel()
+	if err != nil && !errors.Is(quitCtx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+
+	alive, windows := pids, []string(nil)
+	if closer, ok := packer.(apps.WindowCloser); ok && closer.ClosesWindows() {
+		alive = nil
+		if windows = psm.waitWindowsClosed(ctx, app, deadline); len(windows) > 0 {
+			alive = utils.WaitExit(ctx, pids, 0)
+		}
+	} else {
+		alive = utils.WaitExit(ctx, pids, time.Until(deadline))
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if strategy := psm.quitStrategyOf(app); len(alive) > 0 && psm.opt.force && isForceQuit(strategy) {
+		log.Printf("%s did not quit within %s, sending SIGTERM to %v\n", app, time.Duration(psm.conf.QuitTimeout), alive)
+		if err := utils.SignalPIDs(alive, syscall.SIGTERM); err != nil {
+			return err
+		}
+		alive = utils.WaitExit(ctx, alive, termGrace)
+		if len(alive) > 0 && strategy == quitKill {
+			log.Printf("%s did not exit after SIGTERM, sending SIGKILL to %v\n", app, alive)
+			if err := utils.SignalPIDs(alive, syscall.SIGKILL); err != nil {
+				return err
+			}
+			alive = utils.WaitExit(ctx, alive, termGrace)
+		}
+		if len(alive) == 0 {
+			windows = nil
+		}
+	}
+
+	if len(alive) == 0 && len(windows) == 0 {
+		psm.forgetHidden(app)
+		return nil
+	}
+	if windows == nil {
+		windows, _ = utils.GetCurrenWindowsFile(ctx, app)
+	}
+	return &QuitError{App: app, PIDs: alive, Windows: windows}
+}
+
+// waitWindowsClosed polls the windows of app until none is left or deadline
+// passed, it returns the titles of the windows still open.
+func (psm *ProjSnapMaster) waitWindowsClosed(ctx context.Context, app string, deadline time.Time) []string {
+	for {
+		windows, err := utils.GetCurrenWindowsFile(ctx, app)
+		if err == nil && len(windows) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) || utils.Sleep(ctx, quitPollInterval) != nil {
+			return windows
+		}
+	}
+}
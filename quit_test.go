@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCheckForceQuit(t *testing.T) {
+	psm := &ProjSnapMaster{opt: &ProjSnapOptions{}, conf: &ProjSnapConfig{Apps: map[string]AppPolicy{
+		"Xcode":  {Quit: quitKill},
+		"goland": {Quit: quitTerm, Hide: true},
+		"Slack":  {Quit: "nuke"},
+	}}}
+	if err := psm.checkForceQuit([]string{"Finder", "goland"}, false); err != nil {
+		t.Fatalf("graceful and hidden apps need no --force, err: %v", err)
+	}
+	if err := psm.checkForceQuit([]string{"Finder", "Xcode"}, false); err == nil {
+		t.Fatal("expect Xcode to need --force")
+	}
+	if err := psm.checkForceQuit([]string{"Xcode"}, true); err != nil {
+		t.Fatalf("hidden apps are not quit, err: %v", err)
+	}
+	if err := psm.checkForceQuit([]string{"Slack"}, false); err == nil {
+		t.Fatal("expect unknown quit strategy")
+	}
+
+	psm.opt.force = true
+	if err := psm.checkForceQuit([]string{"Finder", "Xcode"}, false); err != nil {
+		t.Fatalf("--force allows Xcode, err: %v", err)
+	}
+}
+
+func TestQuitError(t *testing.T) {
+	err := &QuitError{App: "TextEdit", PIDs: []int{42}, Windows: []string{"Untitled"}}
+	want := "TextEdit refused to quit, pids [42] still running, 1 windows open: Untitled"
+	if err.Error() != want {
+		t.Fatalf("got %q, want %q", err.Error(), want)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"projsnap/utils"
+	"sort"
+	"time"
+)
+
+// windowExpect is the windows one app should show before it is restored, steps
+// point into RestoreJournal.Windows.
+type windowExpect struct {
+	app      string
+	bundleID string
+	pids     []int
+	titles   []string
+	steps    []int
+}
+
+func (psm *ProjSnapMaster) stepStatus(step *JournalStep) string {
+	psm.mu.Lock()
+	defer psm.mu.Unlock()
+	return step.Status
+}
+
+// restoreWindowsWhenReady polls the window manager with backoff and restores
+// the windows of every app as soon as it has been opened and all of its
+// expected windows are up. It runs next to the opening of the apps, opened is
+// closed once they are all launched, from then on the apps get ready_timeout
+// to show up before the rest is restored as it is.
+func (psm *ProjSnapMaster) restoreWindowsWhenReady(ctx context.Context, j *RestoreJournal, appSnapshots []AppSnapshot, want func(JournalStep) bool, opened <-chan struct{}) error {
+	byApp := make(map[string]*windowExpect)
+	for i, step := range j.Windows {
+		if !want(step) {
+			continue
+		}
+		win := appSnapshots[step.Index].WindowInfo
+		if win == nil {
+			// left out by the preflight
+			continue
+		}
+		expect, ok := byApp[win.App]
+		if !ok {
+			expect = &windowExpect{app: win.App}
+			if identity := appSnapshots[step.Index].AppConfig.Identity; identity != nil {
+				expect.bundleID = identity.BundleID
+			}
+			byApp[win.App] = expect
+		}
+		expect.steps = append(expect.steps, i)
+		if win.Title != "" {
+			expect.titles = append(expect.titles, win.Title)
+		}
+	}
+	pending := make([]*windowExpect, 0, len(byApp))
+	for _, expect := range byApp {
+		pending = append(pending, expect)
+	}
+	sort.Slice(pending, func(a, b int) bool { return pending[a].app < pending[b].app })
+
+	var deadline time.Time
+	interval := time.Duration(psm.conf.ReadyInterval)
+	for len(pending) > 0 {
+		if deadline.IsZero() {
+			select {
+			case <-opened:
+				deadline = time.Now().Add(time.Duration(psm.conf.ReadyTimeout))
+			default:
+			}
+		}
+		timedOut := !deadline.IsZero() && time.Now().After(deadline)
+
+		if err := psm.wm.TakeSnapshot(ctx); err != nil {
+			return err
+		}
+		waiting := make([]*windowExpect, 0, len(pending))
+		for _, expect := range pending {
+			launched := true
+			for _, i := range expect.steps {
+				if psm.stepStatus(&j.Apps[j.Windows[i].Index]) == stepPending {
+					launched = false
+				}
+			}
+			if launched && expect.bundleID != "" && len(expect.pids) == 0 {
+				// the app may run under another display name than captured
+				expect.pids, _ = utils.GetBundlePIDs(ctx, expect.bundleID)
+			}
+			ready := launched && psm.wm.WindowsReady(expect.app, expect.pids, len(expect.steps), expect.titles)
+			if !ready && !timedOut {
+				waiting = append(waiting, expect)
+				continue
+			}
+			if !ready {
+				psm.emitError(expect.app, fmt.Errorf("not ready after %s, restore the windows it has", time.Duration(psm.conf.ReadyTimeout)))
+			}
+			for _, i := range expect.steps {
+				err := psm.wm.RestoreWindow(ctx, appSnapshots[j.Windows[i].Index].WindowInfo, expect.pids)
+				psm.markStep(j, kindWindow, &j.Windows[i], stepRestored, err)
+			}
+		}
+		pending = waiting
+		if len(pending) == 0 {
+			break
+		}
+
+		if err := utils.Sleep(ctx, interval); err != nil {
+			return err
+		}
+		interval = min(interval*2, time.Duration(psm.conf.ReadyMaxInterval))
+	}
+	return nil
+}
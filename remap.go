@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"path/filepath"
+	"projsnap/apps"
+	"projsnap/utils"
+	"strings"
+)
+
+// RemapRule rewrites paths under From to the same path under To, e.g. a repo
+// that moved or the home of another user. Both may start with ~.
+type RemapRule struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// parseRemapRule parses "from=to" of --remap.
+func parseRemapRule(s string) (RemapRule, error) {
+	from, to, ok := strings.Cut(s, "=")
+	if !ok || from == "" || to == "" {
+		return RemapRule{}, fmt.Errorf("invalid remap rule %q, want from=to", s)
+	}
+	return RemapRule{From: from, To: to}, nil
+}
+
+// remapRules are the rules of --remap followed by the ones of the config, the
+// first matching rule wins.
+func (psm *ProjSnapMaster) remapRules() []RemapRule {
+	return append(append([]RemapRule{}, psm.opt.remap...), psm.conf.Remap...)
+}
+
+func remapPath(path string, rules []RemapRule) (string, bool) {
+	for _, rule := range rules {
+		from, _ := utils.ExpandUser(rule.From)
+		from = strings.TrimSuffix(from, "/")
+		if path != from && !strings.HasPrefix(path, from+"/") {
+			continue
+		}
+		to, _ := utils.ExpandUser(rule.To)
+		return filepath.Join(to, strings.TrimPrefix(path, from)), true
+	}
+	return path, false
+}
+
+// isPathAttachment reports whether an attachment is a path rather than the
+// content of a file, e.g. the workspace.json of Obsidian.
+func isPathAttachment(attachment string) bool {
+	return !strings.Contains(attachment, "\n") && (strings.HasPrefix(attachment, "/") || strings.HasPrefix(attachment, "~"))
+}
+
+// remapSnapshots applies rules to the Args and path attachments of every
+// entry, it returns copies of the changed entries and how many items changed.
+func remapSnapshots(appSnapshots []AppSnapshot, rules []RemapRule) ([]AppSnapshot, int) {
+	if len(rules) == 0 {
+		return appSnapshots, 0
+	}
+	result := make([]AppSnapshot, len(appSnapshots))
+	changed := 0
+	for i, snap := range appSnapshots {
+		result[i] = snap
+		if snap.AppConfig == nil {
+			continue
+		}
+		conf := *snap.AppConfig
+		n := 0
+		conf.Args = make(apps.PackConfig, len(snap.Args))
+		for k, arg := range snap.Args {
+			if p, ok := remapPath(arg, rules); ok {
+				arg = p
+				n++
+			}
+			conf.Args[k] = arg
+		}
+		conf.Attachments = make([]string, len(snap.Attachments))
+		for k, attachment := range snap.Attachments {
+			if isPathAttachment(attachment) {
+				if p, ok := remapPath(attachment, rules); ok {
+					attachment = p
+					n++
+				}
+			}
+			conf.Attachments[k] = attachment
+		}
+		if n > 0 {
+			result[i].AppConfig = &conf
+			changed += n
+		}
+	}
+	return result, changed
+}
+
+// loadRestoreSnapshot loads snapName with the remap rules and app aliases
+// applied, as it is checked and restored.
+func (psm *ProjSnapMaster) loadRestoreSnapshot(snapName string) ([]AppSnapshot, error) {
+	appSnapshots, err := psm.loadSnapshot(snapName)
+	if err != nil {
+		return nil, err
+	}
+	appSnapshots, _ = remapSnapshots(appSnapshots, psm.remapRules())
+	return psm.applyAliases(appSnapshots), nil
+}
+
+// RemapSnapshot rewrites the paths stored in snapName with rules for good, it
+// returns how many args and attachments changed.
+func (psm *ProjSnapMaster) RemapSnapshot(snapName string, rules []RemapRule) (int, error) {
+	appSnapshots, err := psm.loadSnapshot(snapName)
+	if err != nil {
+		return 0, err
+	}
+	appSnapshots, changed := remapSnapshots(appSnapshots, rules)
+	if changed == 0 {
+		return 0, nil
+	}
+	data, err := json.Marshal(appSnapshots)
+	if err != nil {
+		return 0, err
+	}
+	key := psm.meta.ManifestSnapshots[snapName].SnapshotKey
+	return changed, psm.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(SnapshotsBucketName).Put([]byte(key), data)
+	})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"projsnap/apps"
+	"reflect"
+	"testing"
+)
+
+func TestRemapSnapshots(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip(err)
+	}
+	rules := []RemapRule{
+		{From: "/Users/alice/work/", To: "~/code"},
+		{From: "/Users/alice", To: "/home/bob"},
+	}
+	appSnapshots := []AppSnapshot{
+		{AppConfig: &apps.AppConfig{AppName: "goland", Args: []string{"/Users/alice/work/api", "/Users/alice/workshop"}}},
+		{AppConfig: &apps.AppConfig{AppName: "Microsoft Edge", Args: []string{"https://example.com/Users/alice"}}},
+		{AppConfig: &apps.AppConfig{
+			AppName:     "Obsidian",
+			Args:        []string{"/Users/alice/notes/.obsidian/workspace.json"},
+			Attachments: []string{"{\n\"main\": \"/Users/alice/notes\"\n}", "/Users/alice/work"},
+		}},
+	}
+
+	remapped, changed := remapSnapshots(appSnapshots, rules)
+	if changed != 4 {
+		t.Fatalf("changed: %d, want 4", changed)
+	}
+	want := []string{filepath.Join(home, "code", "api"), "/home/bob/workshop"}
+	if !reflect.DeepEqual([]string(remapped[0].Args), want) {
+		t.Fatalf("goland args: %v, want %v", remapped[0].Args, want)
+	}
+	if remapped[1].AppConfig != appSnapshots[1].AppConfig {
+		t.Fatal("urls are not paths")
+	}
+	want = []string{appSnapshots[2].Attachments[0], filepath.Join(home, "code")}
+	if !reflect.DeepEqual(remapped[2].Attachments, want) {
+		t.Fatalf("obsidian attachments: %v, want %v", remapped[2].Attachments, want)
+	}
+	if appSnapshots[0].Args[0] != "/Users/alice/work/api" {
+		t.Fatal("remapSnapshots must not change the snapshot it was given")
+	}
+
+	if _, err := parseRemapRule("/Users/alice"); err == nil {
+		t.Fatal("expect invalid rule")
+	}
+}
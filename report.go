@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	failOnAny      = "any"
+	failOnAll      = "all"
+	failOnCritical = "critical"
+)
+
+const (
+	kindApp    = "app"
+	kindQuit   = "quit"
+	kindWindow = "window"
+)
+
+type StepResult struct {
+	Kind   string
+	App    string
+	Status string
+	Err    error
+}
+
+// RestoreReport is the outcome of every app and window of a restore or switch.
+// It is returned as an error by Check, so callers can use errors.As on it.
+type RestoreReport struct {
+	Action   string
+	Snapshot string
+	Results  []StepResult
+	Elapsed  time.Duration
+}
+
+func newRestoreReport(j *RestoreJournal) *RestoreReport {
+	report := &RestoreReport{Action: j.Action, Snapshot: j.Snapshot}
+	add := func(kind string, steps []JournalStep) {
+		for _, step := range steps {
+			result := StepResult{Kind: kind, App: step.App, Status: step.Status}
+			if step.Error != "" {
+				result.Err = errors.New(step.Error)
+			}
+			report.Results = append(report.Results, result)
+		}
+	}
+	add(kindApp, j.Apps)
+	add(kindQuit, j.Quits)
+	add(kindWindow, j.Windows)
+	return report
+}
+
+func (r *RestoreReport) Failed() []StepResult {
+	failed := make([]StepResult, 0)
+	for _, result := range r.Results {
+		if result.Status == stepFailed {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+func (r *RestoreReport) Error() string {
+	failed := r.Failed()
+	apps := make([]string, 0, len(failed))
+	for _, result := range failed {
+		apps = append(apps, fmt.Sprintf("%s %s", result.Kind, result.App))
+	}
+	return fmt.Sprintf("%s %s: %d of %d steps failed (%s)", r.Action, r.Snapshot, len(failed), len(r.Results), strings.Join(apps, ", "))
+}
+
+// Check applies the failure policy: any step failed, all apps failed to open,
+// or one of the critical apps failed. It returns the report itself on failure.
+func (r *RestoreReport) Check(policy string, criticalApps []string) error {
+	failed := r.Failed()
+	switch policy {
+	case failOnAny, "":
+		if len(failed) > 0 {
+			return r
+		}
+	case failOnAll:
+		total, failedApps := 0, 0
+		for _, result := range r.Results {
+			if result.Kind == kindApp {
+				total++
+				if result.Status == stepFailed {
+					failedApps++
+				}
+			}
+		}
+		if total > 0 && failedApps == total {
+			return r
+		}
+	case failOnCritical:
+		for _, result := range failed {
+			for _, app := range criticalApps {
+				if strings.EqualFold(app, result.App) {
+					return r
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("unknown failure policy: %s", policy)
+	}
+	return nil
+}
+
+func (r *RestoreReport) Print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tAPP\tSTATUS\tERROR")
+	for _, result := range r.Results {
+		errMsg := ""
+		if result.Err != nil {
+			errMsg = result.Err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", result.Kind, result.App, result.Status, errMsg)
+	}
+	_ = tw.Flush()
+	fmt.Fprintf(w, "%s %s took %s\n", r.Action, r.Snapshot, r.Elapsed.Round(time.Millisecond))
+}
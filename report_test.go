@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRestoreReportCheck(t *testing.T) {
+	j := &RestoreJournal{
+		Action:   actionRestore,
+		Snapshot: "work",
+		Apps: []JournalStep{
+			{App: "goland", Status: stepOpened},
+			{App: "Slack", Status: stepFailed, Error: "not installed"},
+		},
+		Windows: []JournalStep{{App: "goland", Status: stepRestored}},
+	}
+	report := newRestoreReport(j)
+	if failed := report.Failed(); len(failed) != 1 || failed[0].App != "Slack" || failed[0].Err == nil {
+		t.Fatalf("unexpected failed steps: %v", failed)
+	}
+
+	err := report.Check(failOnAny, nil)
+	var target *RestoreReport
+	if !errors.As(err, &target) || target != report {
+		t.Fatalf("expect the report as error, got %v", err)
+	}
+	if err := report.Check(failOnAll, nil); err != nil {
+		t.Fatalf("not all apps failed, got %v", err)
+	}
+	if err := report.Check(failOnCritical, []string{"goland"}); err != nil {
+		t.Fatalf("critical app succeeded, got %v", err)
+	}
+	if err := report.Check(failOnCritical, []string{"slack"}); err == nil {
+		t.Fatal("expect critical failure")
+	}
+	if err := report.Check("sometimes", nil); err == nil {
+		t.Fatal("expect unknown policy error")
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"projsnap/apps"
+	"strings"
+)
+
+const (
+	switchOpen     = "open"
+	switchSkip     = "skip"
+	switchPatch    = "patch"
+	switchRelaunch = "relaunch"
+	switchUnhide   = "unhide"
+)
+
+// appGroup is the snapshot entries of one app, indexes point into the slice
+// they were grouped from.
+type appGroup struct {
+	app     string
+	confs   []AppSnapshot
+	indexes []int
+}
+
+// appSwitch is what SwitchSnapshot does with one app of the target snapshot.
+type appSwitch struct {
+	*appGroup
+	action  string
+	missing []string
+}
+
+func groupAppSnapshots(appSnapshots []AppSnapshot) []*appGroup {
+	byApp := make(map[string]*appGroup)
+	groups := make([]*appGroup, 0)
+	for i, conf := range appSnapshots {
+		group, ok := byApp[conf.AppName]
+		if !ok {
+			group = &appGroup{app: conf.AppName}
+			byApp[conf.AppName] = group
+			groups = append(groups, group)
+		}
+		group.confs = append(group.confs, conf)
+		group.indexes = append(group.indexes, i)
+	}
+	return groups
+}
+
+func flattenArgs(confs []apps.AppConfig) []string {
+	args := make([]string, 0)
+	for _, conf := range confs {
+		args = append(args, conf.Args...)
+	}
+	return args
+}
+
+// hasArg reports whether live contains arg, live entries without a directory
+// (e.g. draw.io window titles) match on the file name only.
+func hasArg(live []string, arg string) bool {
+	for _, v := range live {
+		if v == arg || (!strings.Contains(v, "/") && v == filepath.Base(arg)) {
+			return true
+		}
+	}
+	return false
+}
+
+func missingArgs(live []string, target []string) []string {
+	missing := make([]string, 0)
+	for _, arg := range target {
+		if !hasArg(live, arg) && !hasArg(missing, arg) {
+			missing = append(missing, arg)
+		}
+	}
+	return missing
+}
+
+// planAppSwitch groups the target snapshot by app and compares every running
+// app with its live state, apps that already match are left untouched.
+func (psm *ProjSnapMaster) planAppSwitch(ctx context.Context, appSnapshots []AppSnapshot, realRunning map[string]struct{}) []*appSwitch {
+	switches := make([]*appSwitch, 0)
+	for _, group := range groupAppSnapshots(appSnapshots) {
+		switches = append(switches, &appSwitch{appGroup: group, action: switchOpen})
+	}
+
+	for _, sw := range switches {
+		if _, running := realRunning[sw.app]; !running {
+			continue
+		}
+		sw.action = switchRelaunch
+		live, err := psm.capture(ctx, sw.app, true)
+		if err != nil {
+			psm.emitError(sw.app, fmt.Errorf("capture live state fail, relaunch it: %w", err))
+			continue
+		}
+		target := make([]string, 0)
+		for _, conf := range sw.confs {
+			target = append(target, conf.Args...)
+		}
+		sw.missing = missingArgs(flattenArgs(live), target)
+		if len(sw.missing) == 0 {
+			sw.action = switchSkip
+		} else if _, ok := psm.GetPacker(sw.app).(apps.AppPatcher); ok {
+			sw.action = switchPatch
+		}
+	}
+	return switches
+}
+
+// applyAppSwitch runs switches concurrently in the waves of their lifecycle
+// policies and keeps going past failures, done is told the outcome of every
+// snapshot entry by its index in the slice given to planAppSwitch. Only a
+// dependency cycle is returned.
+func (psm *ProjSnapMaster) applyAppSwitch(ctx context.Context, switches []*appSwitch, done func(int, error)) error {
+	names := make([]string, 0, len(switches))
+	for _, sw := range switches {
+		names = append(names, sw.app)
+	}
+	waves, err := psm.openWaves(names)
+	if err != nil {
+		return err
+	}
+	psm.runWaves(ctx, names, waves, func(ctx context.Context, i int) {
+		sw := switches[i]
+		switch sw.action {
+		case switchSkip:
+			psm.emit(Event{Type: EventAppOpening, App: sw.app, Status: sw.action})
+			for _, idx := range sw.indexes {
+				done(idx, nil)
+			}
+		case switchPatch:
+			psm.emit(Event{Type: EventAppOpening, App: sw.app, Args: sw.missing, Status: sw.action})
+			err := psm.patch(ctx, sw.confs[0].AppConfig, sw.missing)
+			for _, idx := range sw.indexes {
+				done(idx, err)
+			}
+		default:
+			psm.openApp(ctx, sw.appGroup, sw.action == switchRelaunch, done)
+		}
+	})
+	return nil
+}
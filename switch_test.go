@@ -0,0 +1,18 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMissingArgs(t *testing.T) {
+	live := []string{"/work/api", "diagram.drawio", "https://example.com"}
+	target := []string{"/work/api", "/docs/diagram.drawio", "/work/web", "https://example.com", "/work/web"}
+	missing := missingArgs(live, target)
+	if !slices.Equal(missing, []string{"/work/web"}) {
+		t.Fatalf("unexpected missing args: %v", missing)
+	}
+	if missing := missingArgs(target, live[:1]); len(missing) != 0 {
+		t.Fatalf("expect no missing args, got %v", missing)
+	}
+}
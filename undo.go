@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const undoPrefix = ".undo-"
+
+func isUndoSnapshot(snapName string) bool {
+	return strings.HasPrefix(snapName, undoPrefix)
+}
+
+// undoSnapshots returns the hidden undo snapshots, newest first.
+func (psm *ProjSnapMaster) undoSnapshots() []ProjSnapManifest {
+	result := make([]ProjSnapManifest, 0)
+	for name, snapshot := range psm.meta.ManifestSnapshots {
+		if snapshot.Hidden && isUndoSnapshot(name) {
+			result = append(result, snapshot)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		ki, _ := strconv.ParseUint(result[i].SnapshotKey, 10, 64)
+		kj, _ := strconv.ParseUint(result[j].SnapshotKey, 10, 64)
+		return ki > kj
+	})
+	return result
+}
+
+// saveUndo captures the current desktop into a hidden rolling snapshot before
+// switch or restore changes anything, only undo_levels of them are kept.
+func (psm *ProjSnapMaster) saveUndo(ctx context.Context) error {
+	if psm.opt.noUndo || psm.conf.UndoLevels <= 0 {
+		return nil
+	}
+	psm.emitPhase(phaseUndo)
+	appNames, err := psm.getAllApplication(ctx)
+	if err != nil {
+		return err
+	}
+	if err := psm.wm.TakeSnapshot(ctx); err != nil {
+		return err
+	}
+	appSnapshots, err := psm.collectAppSnapshots(ctx, appNames, true)
+	if err != nil {
+		return err
+	}
+	undoName := undoPrefix + strconv.FormatInt(time.Now().UnixNano(), 10)
+	if _, err := psm.dumpProjSnapshot(undoName, appSnapshots, true); err != nil {
+		return fmt.Errorf("save undo snapshot fail, err: %v", err)
+	}
+	undos := psm.undoSnapshots()
+	for i := psm.conf.UndoLevels; i < len(undos); i++ {
+		if err := psm.RemoveSnapshots(undos[i].SnapshotName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Undo returns to the desktop captured before the last switch or restore, the
+// apps opened since then are quit.
+func (psm *ProjSnapMaster) Undo(ctx context.Context) (*RestoreReport, error) {
+	undos := psm.undoSnapshots()
+	if len(undos) == 0 {
+		return nil, errors.New("nothing to undo")
+	}
+	undoName := undos[0].SnapshotName
+	log.Printf("Undo to %s, captured at %s\n", undoName, time.Unix(undos[0].Ctime, 0).String())
+
+	noUndo := psm.opt.noUndo
+	psm.opt.noUndo = true
+	defer func() { psm.opt.noUndo = noUndo }()
+	report, err := psm.SwitchSnapshot(ctx, undoName)
+	if err != nil {
+		return report, err
+	}
+	return report, psm.RemoveSnapshots(undoName)
+}
@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// Darwin is the macOS platform, apps are driven through Launch Services and
+// System Events.
+type Darwin struct{}
+
+func (Darwin) RunningApps(ctx context.Context) ([]RunningApp, error) {
+	script := `
+	tell application "System Events"
+		set out to ""
+		repeat with p in (processes where background only is false)
+			set bundleID to ""
+			set appPath to ""
+			try
+				set bundleID to bundle identifier of p
+				set appPath to POSIX path of (application file of p)
+			end try
+			set out to out & name of p & tab & bundleID & tab & (unix id of p) & tab & appPath & linefeed
+		end repeat
+		return out
+	end tell`
+	result := make([]RunningApp, 0)
+	err := RunOsascriptWithSplit(ctx, script, func(out string) error {
+		var err error
+		result, err = parseRunningApps(out)
+		return err
+	})
+	return result, err
+}
+
+func (Darwin) Launch(ctx context.Context, appName string, args ...string) error {
+	if appName == "" && len(args) == 0 {
+		return errors.New("no app name or args")
+	}
+	allArgs := make([]string, 0)
+	if appName != "" {
+		allArgs = append(allArgs, "-a", appName)
+	}
+	allArgs = append(allArgs, args...)
+
+	cmd := exec.CommandContext(ctx, "open", allArgs...)
+	return cmd.Run()
+}
+
+func (Darwin) Quit(ctx context.Context, appName string) error {
+	script := fmt.Sprintf(`if application "%s" is running then quit app "%s"`, appName, appName)
+	return exec.CommandContext(ctx, "osascript", "-e", script).Run()
+}
+
+func (Darwin) WindowTitles(ctx context.Context, appName string) ([]string, error) {
+	script := fmt.Sprintf(`
+	tell application "System Events"
+		set appName to "%s"
+		set winTitles to {}
+		repeat with w in windows of application process appName
+			set end of winTitles to name of w
+		end repeat
+		return winTitles
+	end tell`, appName)
+	return RunOsascript(ctx, script)
+}
+
+func (Darwin) PIDs(ctx context.Context, appName string) ([]int, error) {
+	script := fmt.Sprintf(`
+	tell application "System Events"
+		get unix id of (processes whose name is "%s")
+	end tell`, appName)
+	return parsePIDs(RunOsascript(ctx, script))
+}
+
+func (Darwin) AppPath(ctx context.Context, bundleID string) (string, error) {
+	out, err := exec.CommandContext(ctx, "mdfind", fmt.Sprintf("kMDItemCFBundleIdentifier == '%s'", bundleID)).Output()
+	if err != nil {
+		return "", fmt.Errorf("mdfind failed: %w", err)
+	}
+	for _, path := range strings.Split(string(out), "\n") {
+		if strings.HasSuffix(path, ".app") {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no app found for bundle id %s", bundleID)
+}
+
+func (Darwin) Installed(ctx context.Context, appName string) bool {
+	return exec.CommandContext(ctx, "open", "-Ra", appName).Run() == nil
+}
+
+func (Darwin) SetVisible(ctx context.Context, appName string, visible bool) error {
+	script := fmt.Sprintf(`
+	tell application "System Events"
+		if exists process "%s" then set visible of process "%s" to %t
+	end tell`, appName, appName, visible)
+	_, err := RunOsascript(ctx, script)
+	return err
+}
+
+func (Darwin) Alive(pid int) bool {
+	return processExists(pid)
+}
+
+func (Darwin) Signal(pid int, sig syscall.Signal) error {
+	return syscall.Kill(pid, sig)
+}
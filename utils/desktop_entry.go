@@ -0,0 +1,308 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DesktopEntry is an application of an XDG .desktop file.
+type DesktopEntry struct {
+	// ID is the desktop file ID, the path below applications/ with "/"
+	// turned into "-", e.g. "org.gnome.Nautilus.desktop".
+	ID        string
+	Path      string
+	Name      string
+	Exec      string
+	TryExec   string
+	Icon      string
+	WMClass   string
+	NoDisplay bool
+	// Terminal entries run in a terminal emulator, their process is no app
+	// of its own.
+	Terminal bool
+}
+
+// parseDesktopEntry reads the [Desktop Entry] group of a .desktop file, ok is
+// false for entries that are not applications or are hidden.
+func parseDesktopEntry(r io.Reader) (entry DesktopEntry, ok bool, err error) {
+	scanner := bufio.NewScanner(r)
+	inGroup, typ, hidden := false, "", false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			inGroup = line == "[Desktop Entry]"
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !inGroup || !found {
+			continue
+		}
+		value = unescapeDesktopValue(strings.TrimSpace(value))
+		switch strings.TrimSpace(key) {
+		case "Type":
+			typ = value
+		case "Name":
+			entry.Name = value
+		case "Exec":
+			entry.Exec = value
+		case "TryExec":
+			entry.TryExec = value
+		case "Icon":
+			entry.Icon = value
+		case "StartupWMClass":
+			entry.WMClass = value
+		case "NoDisplay":
+			entry.NoDisplay = value == "true"
+		case "Terminal":
+			entry.Terminal = value == "true"
+		case "Hidden":
+			hidden = value == "true"
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return entry, false, err
+	}
+	return entry, typ == "Application" && !hidden && entry.Exec != "", nil
+}
+
+// unescapeDesktopValue resolves the escapes of a string value, \s \n \t \r
+// and \\.
+func unescapeDesktopValue(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 's':
+			b.WriteByte(' ')
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case '\\':
+			b.WriteByte('\\')
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// loadDesktopEntries reads the applications/ directory of every data dir, an
+// ID found in an earlier dir hides the same ID of the later ones.
+func loadDesktopEntries(dataDirs []string) []DesktopEntry {
+	seen := make(map[string]bool)
+	entries := make([]DesktopEntry, 0)
+	for _, dataDir := range dataDirs {
+		root := filepath.Join(dataDir, "applications")
+		_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(path, ".desktop") {
+				return nil
+			}
+			rel, _ := filepath.Rel(root, path)
+			id := strings.ReplaceAll(filepath.ToSlash(rel), "/", "-")
+			if seen[id] {
+				return nil
+			}
+			seen[id] = true
+			entry, ok, err := readDesktopEntry(path)
+			if err != nil || !ok {
+				return nil
+			}
+			entry.ID = id
+			entries = append(entries, entry)
+			return nil
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries
+}
+
+func readDesktopEntry(path string) (DesktopEntry, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return DesktopEntry{}, false, err
+	}
+	defer f.Close()
+	entry, ok, err := parseDesktopEntry(f)
+	entry.Path = path
+	entry.ID = filepath.Base(path)
+	return entry, ok, err
+}
+
+// splitExec splits an Exec value into arguments by its quoting rules, inside
+// double quotes a backslash escapes the next character.
+func splitExec(s string) ([]string, error) {
+	args := make([]string, 0)
+	var cur strings.Builder
+	inQuote, has := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case inQuote && c == '\\' && i+1 < len(s):
+			i++
+			cur.WriteByte(s[i])
+		case c == '"':
+			inQuote, has = !inQuote, true
+		case !inQuote && (c == ' ' || c == '\t'):
+			if has {
+				args = append(args, cur.String())
+				cur.Reset()
+				has = false
+			}
+		default:
+			cur.WriteByte(c)
+			has = true
+		}
+	}
+	if inQuote {
+		return nil, fmt.Errorf("unterminated quote in Exec %q", s)
+	}
+	if has {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+// Commands expands the field codes of Exec for files, the files and URLs to
+// open. An Exec taking a single %f or %u runs once per file, an Exec without
+// any file code gets the files appended.
+func (e *DesktopEntry) Commands(files []string) ([][]string, error) {
+	tokens, err := splitExec(e.Exec)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%s has an empty Exec", e.ID)
+	}
+	single, multi := false, false
+	for _, tok := range tokens {
+		single = single || strings.Contains(tok, "%f") || strings.Contains(tok, "%u")
+		multi = multi || tok == "%F" || tok == "%U"
+	}
+	switch {
+	case single && len(files) > 1:
+		cmds := make([][]string, 0, len(files))
+		for _, file := range files {
+			cmds = append(cmds, e.expand(tokens, []string{file}))
+		}
+		return cmds, nil
+	case !single && !multi && len(files) > 0:
+		return [][]string{append(e.expand(tokens, nil), files...)}, nil
+	default:
+		return [][]string{e.expand(tokens, files)}, nil
+	}
+}
+
+func (e *DesktopEntry) expand(tokens []string, files []string) []string {
+	argv := make([]string, 0, len(tokens)+len(files))
+	for _, tok := range tokens {
+		switch tok {
+		case "%F", "%U":
+			argv = append(argv, files...)
+			continue
+		case "%i":
+			if e.Icon != "" {
+				argv = append(argv, "--icon", e.Icon)
+			}
+			continue
+		}
+		if !strings.Contains(tok, "%") {
+			argv = append(argv, tok)
+			continue
+		}
+		var b strings.Builder
+		for i := 0; i < len(tok); i++ {
+			if tok[i] != '%' || i+1 == len(tok) {
+				b.WriteByte(tok[i])
+				continue
+			}
+			i++
+			switch tok[i] {
+			case '%':
+				b.WriteByte('%')
+			case 'f', 'u':
+				if len(files) > 0 {
+					b.WriteString(files[0])
+				}
+			case 'c':
+				b.WriteString(e.Name)
+			case 'k':
+				b.WriteString(e.Path)
+			}
+			// deprecated and unknown codes are dropped
+		}
+		if b.Len() > 0 {
+			argv = append(argv, b.String())
+		}
+	}
+	return argv
+}
+
+// genericLaunchers run the program of an entry rather than being it, the
+// processes of every app they start would match their name.
+var genericLaunchers = map[string]bool{
+	"env": true, "flatpak": true, "snap": true, "sh": true, "bash": true, "dash": true, "zsh": true,
+	"python": true, "perl": true, "ruby": true, "node": true, "java": true, "gjs": true, "mono": true,
+	"wine": true, "pkexec": true, "sudo": true, "xdg-open": true, "gtk-launch": true,
+}
+
+// isGenericLauncher reports whether name is one of genericLaunchers, with or
+// without a version, e.g. python3.12.
+func isGenericLauncher(name string) bool {
+	return genericLaunchers[strings.TrimRight(name, "0123456789.")]
+}
+
+// programs returns the executable names a process of e may run as: its Exec
+// and TryExec programs, where they link to, and its window class. Generic
+// launchers are left out.
+func (e *DesktopEntry) programs() []string {
+	names := make([]string, 0, 4)
+	add := func(name string) {
+		if name != "" && !isGenericLauncher(name) {
+			names = append(names, name)
+		}
+	}
+	paths := make([]string, 0, 2)
+	if tokens, err := splitExec(e.Exec); err == nil {
+		// skip an `env VAR=value` prefix
+		for len(tokens) > 0 && (filepath.Base(tokens[0]) == "env" || strings.Contains(tokens[0], "=")) {
+			tokens = tokens[1:]
+		}
+		if len(tokens) > 0 {
+			paths = append(paths, tokens[0])
+		}
+	}
+	if e.TryExec != "" {
+		paths = append(paths, e.TryExec)
+	}
+	for _, path := range paths {
+		add(filepath.Base(path))
+		if resolved, err := exec.LookPath(path); err == nil {
+			if real, err := filepath.EvalSymlinks(resolved); err == nil {
+				add(filepath.Base(real))
+			}
+		}
+	}
+	add(strings.ToLower(e.WMClass))
+	return names
+}
@@ -0,0 +1,265 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// fakePIDBase keeps the pids of a FakeDesktop clear of real processes.
+const fakePIDBase = 1 << 30
+
+// FakeApp is an app installed on a FakeDesktop.
+type FakeApp struct {
+	Name     string
+	BundleID string
+	// Windows titles the windows the app opens for the args of a launch, the
+	// app shows one window titled by its name without.
+	Windows func(args []string) []string
+	// Stubborn apps ignore Quit, only a signal ends them.
+	Stubborn bool
+}
+
+// FakeWindow is a window open on a FakeDesktop, IDs are unique per desktop.
+type FakeWindow struct {
+	ID    int
+	App   string
+	Title string
+	PID   int
+}
+
+type fakeProc struct {
+	app     *FakeApp
+	pid     int
+	hidden  bool
+	windows []FakeWindow
+}
+
+// FakeDesktop is an in-memory Platform for tests. Install the apps it knows,
+// Start the ones already running, and inspect Calls, Running and Windows
+// after driving it.
+type FakeDesktop struct {
+	mu        sync.Mutex
+	installed map[string]*FakeApp
+	running   []*fakeProc
+	nextPID   int
+	nextWin   int
+	calls     []string
+}
+
+func NewFakeDesktop() *FakeDesktop {
+	return &FakeDesktop{
+		installed: make(map[string]*FakeApp),
+		nextPID:   fakePIDBase,
+	}
+}
+
+// Install makes app launchable, by name or bundle identifier.
+func (d *FakeDesktop) Install(app FakeApp) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.installed[app.Name] = &app
+}
+
+// Start runs an installed app with windows titled as titles.
+func (d *FakeDesktop) Start(appName string, titles ...string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	app, ok := d.lookup(appName)
+	if !ok {
+		return fmt.Errorf("%s is not installed", appName)
+	}
+	d.start(app, titles)
+	return nil
+}
+
+// Calls returns what was done to the desktop, e.g. "launch goland /src/api"
+// or "quit Slack".
+func (d *FakeDesktop) Calls() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.calls)
+}
+
+// Running returns the names of the running apps, in launch order.
+func (d *FakeDesktop) Running() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	names := make([]string, 0, len(d.running))
+	for _, proc := range d.running {
+		names = append(names, proc.app.Name)
+	}
+	return names
+}
+
+// Windows returns every window of the visible apps.
+func (d *FakeDesktop) Windows() []FakeWindow {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	windows := make([]FakeWindow, 0)
+	for _, proc := range d.running {
+		if !proc.hidden {
+			windows = append(windows, proc.windows...)
+		}
+	}
+	return windows
+}
+
+// lookup finds an installed app by name, bundle identifier or the path
+// AppPath returned for it.
+func (d *FakeDesktop) lookup(name string) (*FakeApp, bool) {
+	if app, ok := d.installed[name]; ok {
+		return app, true
+	}
+	for _, app := range d.installed {
+		if app.BundleID != "" && (app.BundleID == name || fakeAppPath(app) == name) {
+			return app, true
+		}
+	}
+	return nil, false
+}
+
+func (d *FakeDesktop) proc(appName string) *fakeProc {
+	for _, proc := range d.running {
+		if proc.app.Name == appName {
+			return proc
+		}
+	}
+	return nil
+}
+
+func (d *FakeDesktop) start(app *FakeApp, titles []string) *fakeProc {
+	proc := &fakeProc{app: app, pid: d.nextPID}
+	d.nextPID++
+	d.running = append(d.running, proc)
+	d.open(proc, titles)
+	return proc
+}
+
+func (d *FakeDesktop) open(proc *fakeProc, titles []string) {
+	for _, title := range titles {
+		d.nextWin++
+		proc.windows = append(proc.windows, FakeWindow{ID: d.nextWin, App: proc.app.Name, Title: title, PID: proc.pid})
+	}
+}
+
+func (d *FakeDesktop) exit(pid int) {
+	d.running = slices.DeleteFunc(d.running, func(proc *fakeProc) bool { return proc.pid == pid })
+}
+
+func fakeAppPath(app *FakeApp) string {
+	return filepath.Join("/Applications", app.Name+".app")
+}
+
+func (d *FakeDesktop) RunningApps(context.Context) ([]RunningApp, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	result := make([]RunningApp, 0, len(d.running))
+	for _, proc := range d.running {
+		result = append(result, RunningApp{Name: proc.app.Name, BundleID: proc.app.BundleID, Path: fakeAppPath(proc.app), PID: proc.pid})
+	}
+	return result, nil
+}
+
+func (d *FakeDesktop) Launch(_ context.Context, appName string, args ...string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls = append(d.calls, strings.TrimSpace("launch "+appName+" "+strings.Join(args, " ")))
+	app, ok := d.lookup(appName)
+	if !ok {
+		return fmt.Errorf("unable to find application named '%s'", appName)
+	}
+	titles := []string{app.Name}
+	if app.Windows != nil {
+		titles = app.Windows(args)
+	}
+	proc := d.proc(app.Name)
+	if proc == nil {
+		d.start(app, titles)
+		return nil
+	}
+	proc.hidden = false
+	if len(args) > 0 {
+		d.open(proc, titles)
+	}
+	return nil
+}
+
+func (d *FakeDesktop) Quit(_ context.Context, appName string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls = append(d.calls, "quit "+appName)
+	if proc := d.proc(appName); proc != nil && !proc.app.Stubborn {
+		d.exit(proc.pid)
+	}
+	return nil
+}
+
+func (d *FakeDesktop) WindowTitles(_ context.Context, appName string) ([]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	titles := make([]string, 0)
+	if proc := d.proc(appName); proc != nil {
+		for _, win := range proc.windows {
+			titles = append(titles, win.Title)
+		}
+	}
+	return titles, nil
+}
+
+func (d *FakeDesktop) PIDs(_ context.Context, appName string) ([]int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	pids := make([]int, 0)
+	if proc := d.proc(appName); proc != nil {
+		pids = append(pids, proc.pid)
+	}
+	return pids, nil
+}
+
+func (d *FakeDesktop) AppPath(_ context.Context, id string) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, app := range d.installed {
+		if app.BundleID == id {
+			return fakeAppPath(app), nil
+		}
+	}
+	return "", fmt.Errorf("no app found for bundle id %s", id)
+}
+
+func (d *FakeDesktop) Installed(_ context.Context, appName string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, ok := d.lookup(appName)
+	return ok
+}
+
+func (d *FakeDesktop) SetVisible(_ context.Context, appName string, visible bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if proc := d.proc(appName); proc != nil {
+		proc.hidden = !visible
+	}
+	return nil
+}
+
+func (d *FakeDesktop) Alive(pid int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.ContainsFunc(d.running, func(proc *fakeProc) bool { return proc.pid == pid })
+}
+
+func (d *FakeDesktop) Signal(pid int, sig syscall.Signal) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls = append(d.calls, fmt.Sprintf("signal %d %v", pid, sig))
+	if sig == syscall.SIGTERM || sig == syscall.SIGKILL {
+		d.exit(pid)
+	}
+	return nil
+}
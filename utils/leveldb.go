@@ -3,9 +3,9 @@ package utils
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/opt"
-	"log"
 	"strings"
 )
 
@@ -23,7 +23,7 @@ func ReadRecentFiles(dbPath string) ([]string, error) {
 		ReadOnly: true,
 	})
 	if err != nil {
-		log.Fatalf("failed to open db: %v", err)
+		return nil, fmt.Errorf("failed to open db: %w", err)
 	}
 	defer db.Close()
 
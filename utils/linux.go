@@ -0,0 +1,281 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Linux is the platform of XDG desktops: running apps are the processes of
+// /proc matched to their .desktop entry, launched through its Exec line and
+// quit with SIGTERM. How long an app gets to exit before it is killed is up to
+// the quit strategy of the caller.
+type Linux struct {
+	procDir  string
+	dataDirs []string
+
+	once    sync.Once
+	entries []DesktopEntry
+}
+
+// NewLinux reads the desktop entries of $XDG_DATA_HOME and $XDG_DATA_DIRS.
+func NewLinux() *Linux {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, _ := os.UserHomeDir()
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	dataDirs := os.Getenv("XDG_DATA_DIRS")
+	if dataDirs == "" {
+		dataDirs = "/usr/local/share:/usr/share"
+	}
+	return &Linux{procDir: "/proc", dataDirs: append([]string{dataHome}, filepath.SplitList(dataDirs)...)}
+}
+
+func (l *Linux) desktopEntries() []DesktopEntry {
+	l.once.Do(func() {
+		l.entries = loadDesktopEntries(l.dataDirs)
+	})
+	return l.entries
+}
+
+// entry finds the desktop entry of appName, by desktop file ID with or
+// without ".desktop", by the path of a .desktop file, or by Name.
+func (l *Linux) entry(appName string) (*DesktopEntry, bool) {
+	if strings.HasSuffix(appName, ".desktop") && filepath.IsAbs(appName) {
+		entry, ok, err := readDesktopEntry(appName)
+		return &entry, ok && err == nil
+	}
+	entries := l.desktopEntries()
+	for i := range entries {
+		if entries[i].ID == appName || entries[i].ID == appName+".desktop" {
+			return &entries[i], true
+		}
+	}
+	for i := range entries {
+		if strings.EqualFold(entries[i].Name, appName) {
+			return &entries[i], true
+		}
+	}
+	return nil, false
+}
+
+type linuxProc struct {
+	pid   int
+	exe   string
+	argv0 string
+	comm  string
+}
+
+// processes lists the processes of the current user.
+func (l *Linux) processes() ([]linuxProc, error) {
+	dirs, err := os.ReadDir(l.procDir)
+	if err != nil {
+		return nil, err
+	}
+	uid := uint32(os.Getuid())
+	procs := make([]linuxProc, 0)
+	for _, d := range dirs {
+		pid, err := strconv.Atoi(d.Name())
+		if err != nil || !d.IsDir() {
+			continue
+		}
+		dir := filepath.Join(l.procDir, d.Name())
+		if info, err := os.Stat(dir); err != nil {
+			continue
+		} else if stat, ok := info.Sys().(*syscall.Stat_t); ok && stat.Uid != uid {
+			continue
+		}
+		proc := linuxProc{pid: pid}
+		proc.exe, _ = os.Readlink(filepath.Join(dir, "exe"))
+		if cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+			argv0, _, _ := bytes.Cut(cmdline, []byte{0})
+			proc.argv0 = string(argv0)
+		}
+		if comm, err := os.ReadFile(filepath.Join(dir, "comm")); err == nil {
+			proc.comm = strings.TrimSpace(string(comm))
+		}
+		procs = append(procs, proc)
+	}
+	return procs, nil
+}
+
+func (l *Linux) RunningApps(context.Context) ([]RunningApp, error) {
+	procs, err := l.processes()
+	if err != nil {
+		return nil, err
+	}
+	byProgram := make(map[string]*DesktopEntry)
+	entries := l.desktopEntries()
+	for i := range entries {
+		if entries[i].NoDisplay || entries[i].Terminal {
+			continue
+		}
+		for _, name := range entries[i].programs() {
+			if _, ok := byProgram[name]; !ok {
+				byProgram[name] = &entries[i]
+			}
+			// comm is cut to 15 bytes
+			if _, ok := byProgram["comm:"+name]; !ok && len(name) > 15 {
+				byProgram["comm:"+name[:15]] = &entries[i]
+			}
+		}
+	}
+	result := make([]RunningApp, 0)
+	for _, proc := range procs {
+		entry := byProgram[filepath.Base(proc.exe)]
+		if entry == nil && proc.argv0 != "" {
+			entry = byProgram[filepath.Base(proc.argv0)]
+		}
+		if entry == nil && proc.comm != "" {
+			if entry = byProgram[proc.comm]; entry == nil {
+				entry = byProgram["comm:"+proc.comm]
+			}
+		}
+		if entry != nil {
+			result = append(result, RunningApp{Name: entry.Name, DesktopID: entry.ID, Path: proc.exe, PID: proc.pid})
+		}
+	}
+	return result, nil
+}
+
+// startDetached runs argv in its own session, the app outlives projsnap.
+func startDetached(argv []string) error {
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go func() { _ = cmd.Wait() }()
+	return nil
+}
+
+func (l *Linux) Launch(_ context.Context, appName string, args ...string) error {
+	if appName == "" && len(args) == 0 {
+		return errors.New("no app name or args")
+	}
+	if appName == "" {
+		for _, arg := range args {
+			if err := startDetached([]string{"xdg-open", arg}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	entry, ok := l.entry(appName)
+	if !ok {
+		return fmt.Errorf("no desktop entry found for %s", appName)
+	}
+	cmds, err := entry.Commands(args)
+	if err != nil {
+		return err
+	}
+	for _, argv := range cmds {
+		if err := startDetached(argv); err != nil {
+			return fmt.Errorf("launch %s fail: %w", appName, err)
+		}
+	}
+	return nil
+}
+
+func (l *Linux) Quit(ctx context.Context, appName string) error {
+	pids, err := l.PIDs(ctx, appName)
+	if err != nil {
+		return err
+	}
+	return SignalPIDs(pids, syscall.SIGTERM)
+}
+
+// WindowTitles needs wmctrl, it lists the windows of any EWMH window manager.
+func (l *Linux) WindowTitles(ctx context.Context, appName string) ([]string, error) {
+	pids, err := l.PIDs(ctx, appName)
+	if err != nil {
+		return nil, err
+	}
+	out, err := exec.CommandContext(ctx, "wmctrl", "-lp").Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, fmt.Errorf("window titles need wmctrl: %w", errors.ErrUnsupported)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("wmctrl failed: %w", err)
+	}
+	return parseWmctrlTitles(string(out), pids), nil
+}
+
+// parseWmctrlTitles picks the titles of the windows of pids from the output
+// of `wmctrl -lp`: id, desktop, pid, host and title per line.
+func parseWmctrlTitles(out string, pids []int) []string {
+	titles := make([]string, 0)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[2])
+		if err != nil || !slices.Contains(pids, pid) {
+			continue
+		}
+		title := ""
+		if len(fields) > 4 {
+			// the title keeps its own spaces
+			rest := line
+			for i := 0; i < 4; i++ {
+				rest = strings.TrimLeft(rest, " \t")
+				rest = rest[strings.IndexAny(rest, " \t")+1:]
+			}
+			title = strings.TrimSpace(rest)
+		}
+		titles = append(titles, title)
+	}
+	return titles
+}
+
+func (l *Linux) PIDs(ctx context.Context, appName string) ([]int, error) {
+	running, err := l.RunningApps(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pids := make([]int, 0)
+	for _, app := range running {
+		if app.Name == appName || app.DesktopID == appName {
+			pids = append(pids, app.PID)
+		}
+	}
+	return pids, nil
+}
+
+// AppPath returns the .desktop file of the desktop file ID id.
+func (l *Linux) AppPath(_ context.Context, id string) (string, error) {
+	for _, entry := range l.desktopEntries() {
+		if entry.ID == id {
+			return entry.Path, nil
+		}
+	}
+	return "", fmt.Errorf("no desktop entry %s", id)
+}
+
+func (l *Linux) Installed(_ context.Context, appName string) bool {
+	_, ok := l.entry(appName)
+	return ok
+}
+
+func (l *Linux) SetVisible(_ context.Context, appName string, _ bool) error {
+	return fmt.Errorf("hide %s: %w", appName, errors.ErrUnsupported)
+}
+
+func (l *Linux) Alive(pid int) bool {
+	return processExists(pid)
+}
+
+func (l *Linux) Signal(pid int, sig syscall.Signal) error {
+	return syscall.Kill(pid, sig)
+}
@@ -0,0 +1,165 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDesktopEntryCommands(t *testing.T) {
+	entry, ok, err := parseDesktopEntry(strings.NewReader(`[Desktop Entry]
+Type=Application
+Name=Visual Studio Code
+Exec=env GDK_BACKEND=x11 /usr/share/code/code "--unity-launch" %F
+Icon=vscode
+
+[Desktop Action new-empty-window]
+Exec=/usr/share/code/code --new-window %F
+`))
+	if err != nil || !ok {
+		t.Fatalf("parse: %v %v", ok, err)
+	}
+	if entry.Name != "Visual Studio Code" || entry.Exec != `env GDK_BACKEND=x11 /usr/share/code/code "--unity-launch" %F` {
+		t.Fatalf("actions must not override the entry: %+v", entry)
+	}
+	cmds, err := entry.Commands([]string{"/src/api", "/src/web"})
+	want := [][]string{{"env", "GDK_BACKEND=x11", "/usr/share/code/code", "--unity-launch", "/src/api", "/src/web"}}
+	if err != nil || !reflect.DeepEqual(cmds, want) {
+		t.Fatalf("commands: %q %v", cmds, err)
+	}
+	if programs := entry.programs(); programs[0] != "code" {
+		t.Fatalf("the env prefix must be skipped: %v", programs)
+	}
+	for _, launched := range []DesktopEntry{
+		{Exec: "/usr/bin/flatpak run --branch=stable --arch=x86_64 org.gnome.Maps", WMClass: "org.gnome.Maps"},
+		{Exec: `sh -c "cd ~/notes && exec ./notes"`, TryExec: "sh", WMClass: "Notes"},
+		{Exec: "python3.12 /opt/tool/main.py"},
+	} {
+		if programs := launched.programs(); len(programs) > 1 || (len(programs) == 1 && programs[0] != strings.ToLower(launched.WMClass)) {
+			t.Fatalf("generic launchers must not be indexed: %v", programs)
+		}
+	}
+
+	single := DesktopEntry{Name: "Viewer", Exec: `viewer --title "%c \"x\"" %i 100%% %u`, Icon: "eye"}
+	cmds, _ = single.Commands([]string{"a.png", "b.png"})
+	want = [][]string{
+		{"viewer", "--title", `Viewer "x"`, "--icon", "eye", "100%", "a.png"},
+		{"viewer", "--title", `Viewer "x"`, "--icon", "eye", "100%", "b.png"},
+	}
+	if !reflect.DeepEqual(cmds, want) {
+		t.Fatalf("a %%u Exec runs once per file: %q", cmds)
+	}
+	if cmds, _ = single.Commands(nil); len(cmds[0]) != 6 {
+		t.Fatalf("%%u without files is dropped: %q", cmds)
+	}
+	plain := DesktopEntry{Exec: "gedit"}
+	if cmds, _ = plain.Commands([]string{"notes.txt"}); !reflect.DeepEqual(cmds, [][]string{{"gedit", "notes.txt"}}) {
+		t.Fatalf("files are appended to an Exec without codes: %q", cmds)
+	}
+	if _, err := splitExec(`code "unterminated`); err == nil {
+		t.Fatal("expect an error for an unterminated quote")
+	}
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeProc lays out /proc/<pid> as the kernel shows a process.
+func writeProc(t *testing.T, procDir string, pid int, exe, cmdline, comm string) {
+	dir := filepath.Join(procDir, strconv.Itoa(pid))
+	writeFile(t, filepath.Join(dir, "cmdline"), cmdline)
+	writeFile(t, filepath.Join(dir, "comm"), comm+"\n")
+	if exe != "" {
+		if err := os.Symlink(exe, filepath.Join(dir, "exe")); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLinuxRunningApps(t *testing.T) {
+	root := t.TempDir()
+	home, system := filepath.Join(root, "home"), filepath.Join(root, "usr")
+	writeFile(t, filepath.Join(system, "applications", "org.gnome.gedit.desktop"), "[Desktop Entry]\nType=Application\nName=Text Editor\nExec=gedit %U\n")
+	writeFile(t, filepath.Join(system, "applications", "jetbrains", "goland.desktop"), "[Desktop Entry]\nType=Application\nName=GoLand\nExec=/opt/goland/bin/goland.sh %f\nStartupWMClass=jetbrains-goland\n")
+	writeFile(t, filepath.Join(system, "applications", "gedit-handler.desktop"), "[Desktop Entry]\nType=Application\nName=Handler\nExec=gedit\nNoDisplay=true\n")
+	writeFile(t, filepath.Join(system, "applications", "htop.desktop"), "[Desktop Entry]\nType=Application\nName=Htop\nExec=htop\nTerminal=true\n")
+	writeFile(t, filepath.Join(system, "applications", "bash-script.desktop"), "[Desktop Entry]\nType=Application\nName=Script\nExec=bash -c ~/bin/sync\n")
+	writeFile(t, filepath.Join(system, "applications", "org.gnome.Maps.desktop"), "[Desktop Entry]\nType=Application\nName=Maps\nExec=/usr/bin/flatpak run org.gnome.Maps\n")
+	// the user entry hides the system one
+	writeFile(t, filepath.Join(home, "applications", "org.gnome.gedit.desktop"), "[Desktop Entry]\nType=Application\nName=My Editor\nExec=gedit %U\n")
+
+	procDir := filepath.Join(root, "proc")
+	writeProc(t, procDir, 100, "/usr/bin/gedit", "gedit\x00notes.txt\x00", "gedit")
+	writeProc(t, procDir, 200, "/opt/goland/jbr/bin/java", "/opt/goland/jbr/bin/java\x00-Xmx2g\x00", "jetbrains-golan")
+	writeProc(t, procDir, 300, "/usr/bin/bash", "bash\x00", "bash")
+	writeProc(t, procDir, 400, "", "", "kworker/0:1")
+	// htop in a terminal, and a flatpak command of the shell
+	writeProc(t, procDir, 500, "/usr/bin/htop", "htop\x00", "htop")
+	writeProc(t, procDir, 600, "/usr/bin/flatpak", "flatpak\x00list\x00", "flatpak")
+
+	l := &Linux{procDir: procDir, dataDirs: []string{home, system}}
+	running, err := l.RunningApps(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []RunningApp{
+		{Name: "My Editor", DesktopID: "org.gnome.gedit.desktop", Path: "/usr/bin/gedit", PID: 100},
+		{Name: "GoLand", DesktopID: "jetbrains-goland.desktop", Path: "/opt/goland/jbr/bin/java", PID: 200},
+	}
+	if !reflect.DeepEqual(running, want) {
+		t.Fatalf("running apps:\n%+v\nwant\n%+v", running, want)
+	}
+	if pids, _ := l.PIDs(context.Background(), "GoLand"); !reflect.DeepEqual(pids, []int{200}) {
+		t.Fatalf("pids of GoLand: %v", pids)
+	}
+	if !l.Installed(context.Background(), "my editor") || !l.Installed(context.Background(), "org.gnome.gedit") {
+		t.Fatal("gedit is installed")
+	}
+	if path, err := l.AppPath(context.Background(), "jetbrains-goland.desktop"); err != nil || filepath.Base(path) != "goland.desktop" {
+		t.Fatalf("app path: %s %v", path, err)
+	}
+}
+
+func TestLinuxLaunch(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "applications", "touch.desktop"), "[Desktop Entry]\nType=Application\nName=Toucher\nExec=touch %F\n")
+	l := &Linux{procDir: "/proc", dataDirs: []string{root}}
+	files := []string{filepath.Join(root, "a b"), filepath.Join(root, "c")}
+	if err := l.Launch(context.Background(), "touch", files...); err != nil {
+		t.Skip(err)
+	}
+	for _, file := range files {
+		deadline := time.Now().Add(5 * time.Second)
+		for _, err := os.Stat(file); err != nil; _, err = os.Stat(file) {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s was not opened: %v", file, err)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if err := l.Launch(context.Background(), "Missing"); err == nil {
+		t.Fatal("expect an error for an app without desktop entry")
+	}
+}
+
+func TestParseWmctrlTitles(t *testing.T) {
+	out := "0x03a00003  0 4242   host api – main.go  GoLand\n" +
+		"0x03c00001 -1 77     host Desktop\n" +
+		"0x03a00009  1 4242   host \n"
+	titles := parseWmctrlTitles(out, []int{4242})
+	if !reflect.DeepEqual(titles, []string{"api – main.go  GoLand", ""}) {
+		t.Fatalf("titles: %q", titles)
+	}
+}
@@ -1,25 +1,48 @@
 package utils
 
 import (
-	"errors"
-	"fmt"
+	"context"
 	"log"
 	"os/exec"
+	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 )
 
-type CheckRunningAppFn func() ([]string, error)
+type CheckRunningAppFn func(ctx context.Context) ([]string, error)
 
-func OpenMultiAppByRetry(fn CheckRunningAppFn, appName string, args ...string) error {
+// Sleep waits for d, it returns early with the error of ctx once it is done.
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+const (
+	launchPollInterval = 200 * time.Millisecond
+	launchMaxInterval  = 2 * time.Second
+	// launchReadyTimeout bounds the wait for the window of one argument when
+	// ctx has no earlier deadline.
+	launchReadyTimeout = 30 * time.Second
+)
+
+// OpenMultiAppByRetry opens args as OpenMultiApp does and opens again the ones
+// fn still reports missing, up to 5 times.
+func OpenMultiAppByRetry(ctx context.Context, fn CheckRunningAppFn, launchName, appName string, args ...string) error {
 	retryArgs := args
 	retryCnt := 5
 	for len(retryArgs) != 0 && retryCnt > 0 {
-		err := OpenMultiApp(appName, retryArgs...)
+		err := OpenMultiApp(ctx, launchName, appName, retryArgs...)
 		if err != nil {
 			return err
 		}
-		retryArgs, err = fn()
+		retryArgs, err = fn(ctx)
 		retryCnt--
 		if err != nil {
 			return err
@@ -28,35 +51,49 @@ func OpenMultiAppByRetry(fn CheckRunningAppFn, appName string, args ...string) e
 	return nil
 }
 
-func OpenMultiApp(appName string, args ...string) error {
+// OpenMultiApp opens args one by one through launchName, each once appName
+// shows a window for the previous one: an app still starting drops what it is
+// asked to open meanwhile. The wait for a window is bounded by ctx and
+// launchReadyTimeout, an argument showing none by then is left as it is.
+func OpenMultiApp(ctx context.Context, launchName, appName string, args ...string) error {
 	log.Printf("open multi app: %v", args)
-	timeout := 5 * time.Second
 	for _, arg := range args {
-		if err := OpenApp(appName, arg); err != nil {
+		before, _ := GetCurrenWindowsFile(ctx, appName)
+		if err := OpenApp(ctx, launchName, arg); err != nil {
+			return err
+		}
+		if err := waitArgWindow(ctx, appName, arg, len(before)); err != nil {
 			return err
 		}
-		time.Sleep(timeout)
 	}
-	time.Sleep(timeout)
 	return nil
 }
 
-func OpenApp(appName string, args ...string) error {
-	if appName == "" && len(args) == 0 {
-		return errors.New("no app name or args")
-	}
-	allArgs := make([]string, 0)
-	if appName != "" {
-		allArgs = append(allArgs, "-a", appName)
+// waitArgWindow polls the windows of appName with backoff until one is titled
+// after arg or more than before are open.
+func waitArgWindow(ctx context.Context, appName, arg string, before int) error {
+	deadline := time.Now().Add(launchReadyTimeout)
+	name := filepath.Base(arg)
+	for interval := launchPollInterval; time.Now().Before(deadline); interval = min(interval*2, launchMaxInterval) {
+		titles, err := GetCurrenWindowsFile(ctx, appName)
+		if err == nil && (len(titles) > before || slices.ContainsFunc(titles, func(title string) bool {
+			return strings.Contains(title, name)
+		})) {
+			return nil
+		}
+		if err := Sleep(ctx, interval); err != nil {
+			return err
+		}
 	}
-	allArgs = append(allArgs, args...)
+	return nil
+}
 
-	cmd := exec.Command("open", allArgs...)
-	return cmd.Run()
+func OpenApp(ctx context.Context, appName string, args ...string) error {
+	return CurrentPlatform().Launch(ctx, appName, args...)
 }
 
-func RunOsascriptWithSplit(script string, splitFn func(string) error) error {
-	cmd := exec.Command("osascript", "-e", script)
+func RunOsascriptWithSplit(ctx context.Context, script string, splitFn func(string) error) error {
+	cmd := exec.CommandContext(ctx, "osascript", "-e", script)
 	out, err := cmd.Output()
 	if err != nil {
 		return err
@@ -71,9 +108,9 @@ func RunOsascriptWithSplit(script string, splitFn func(string) error) error {
 //	return result, err
 //}
 
-func RunOsascript(script string) ([]string, error) {
+func RunOsascript(ctx context.Context, script string) ([]string, error) {
 	items := make([]string, 0)
-	err := RunOsascriptWithSplit(script, func(out string) error {
+	err := RunOsascriptWithSplit(ctx, script, func(out string) error {
 		for _, item := range strings.Split(out, ",") {
 			items = append(items, strings.TrimSpace(item))
 		}
@@ -82,24 +119,26 @@ func RunOsascript(script string) ([]string, error) {
 	return items, err
 }
 
-func GracefulQuit(appName string) error {
-	script := fmt.Sprintf(`if application "%s" is running then quit app "%s"`, appName, appName)
-	if err := exec.Command("osascript", "-e", script).Run(); err != nil {
-		return err
-	}
-	time.Sleep(1 * time.Second)
-	return nil
+// GracefulQuit asks appName to quit, it does not wait for the app to exit, use
+// WaitExit or QuitAndWait to confirm it is gone.
+func GracefulQuit(ctx context.Context, appName string) error {
+	return CurrentPlatform().Quit(ctx, appName)
+}
+
+// AppInstalled reports whether the platform finds an app named appName.
+func AppInstalled(ctx context.Context, appName string) bool {
+	return CurrentPlatform().Installed(ctx, appName)
+}
+
+// HideApp hides every window of appName, the app keeps running.
+func HideApp(ctx context.Context, appName string) error {
+	return CurrentPlatform().SetVisible(ctx, appName, false)
+}
+
+func UnhideApp(ctx context.Context, appName string) error {
+	return CurrentPlatform().SetVisible(ctx, appName, true)
 }
 
-func GetCurrenWindowsFile(appName string) ([]string, error) {
-	script := fmt.Sprintf(`
-	tell application "System Events"
-		set appName to "%s"
-		set winTitles to {}
-		repeat with w in windows of application process appName
-			set end of winTitles to name of w
-		end repeat
-		return winTitles
-	end tell`, appName)
-	return RunOsascript(script)
+func GetCurrenWindowsFile(ctx context.Context, appName string) ([]string, error) {
+	return CurrentPlatform().WindowTitles(ctx, appName)
 }
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestOpenMultiApp(t *testing.T) {
+	desktop := NewFakeDesktop()
+	desktop.Install(FakeApp{Name: "GoLand", BundleID: "com.jetbrains.goland", Windows: func(args []string) []string {
+		titles := make([]string, 0)
+		for _, arg := range args {
+			if arg != "/src/slow" {
+				titles = append(titles, filepath.Base(arg)+" – main.go")
+			}
+		}
+		return titles
+	}})
+	prev := SetPlatform(desktop)
+	defer SetPlatform(prev)
+
+	start := time.Now()
+	if err := OpenMultiApp(context.Background(), "/Applications/GoLand.app", "GoLand", "/src/api", "/src/web", "/src/api"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("windows shown at once must not be waited for, took %s", elapsed)
+	}
+	titles, _ := GetCurrenWindowsFile(context.Background(), "GoLand")
+	if !slices.Equal(titles, []string{"api – main.go", "web – main.go", "api – main.go"}) {
+		t.Fatalf("titles: %q", titles)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if err := OpenMultiApp(ctx, "GoLand", "GoLand", "/src/slow", "/src/docs"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("the wait for a window is bounded by ctx, got %v", err)
+	}
+	if calls := desktop.Calls(); calls[len(calls)-1] != "launch GoLand /src/slow" {
+		t.Fatalf("no argument is opened before the window of the previous one: %q", calls)
+	}
+}
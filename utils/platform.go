@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"syscall"
+)
+
+// Platform is the desktop projsnap drives: which apps run, launching and
+// quitting them, their windows and processes. Every helper of this package
+// touching the desktop goes through the current one.
+type Platform interface {
+	// RunningApps lists the apps with a user interface.
+	RunningApps(ctx context.Context) ([]RunningApp, error)
+	// Launch opens appName, or the files and URLs of args with their default
+	// app when appName is "".
+	Launch(ctx context.Context, appName string, args ...string) error
+	// Quit asks appName to quit, it does not wait for the app to exit.
+	Quit(ctx context.Context, appName string) error
+	// WindowTitles returns the titles of the windows appName has open.
+	WindowTitles(ctx context.Context, appName string) ([]string, error)
+	// PIDs returns the pids of the processes named exactly appName.
+	PIDs(ctx context.Context, appName string) ([]int, error)
+	// AppPath returns where the app with the given bundle identifier or
+	// desktop file ID is installed.
+	AppPath(ctx context.Context, id string) (string, error)
+	Installed(ctx context.Context, appName string) bool
+	SetVisible(ctx context.Context, appName string, visible bool) error
+	Alive(pid int) bool
+	Signal(pid int, sig syscall.Signal) error
+}
+
+var (
+	platformMu sync.RWMutex
+	platform   = defaultPlatform()
+)
+
+func defaultPlatform() Platform {
+	if runtime.GOOS == "linux" {
+		return NewLinux()
+	}
+	return Darwin{}
+}
+
+// CurrentPlatform returns the platform the helpers of this package use.
+func CurrentPlatform() Platform {
+	platformMu.RLock()
+	defer platformMu.RUnlock()
+	return platform
+}
+
+// SetPlatform replaces the current platform and returns the previous one.
+func SetPlatform(p Platform) Platform {
+	platformMu.Lock()
+	defer platformMu.Unlock()
+	prev := platform
+	platform = p
+	return prev
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"context"
+	"sync"
+)
+
+// ForEach calls fn for 0..n-1 on at most workers goroutines and waits for all
+// of them, nothing new is started once ctx is done.
+func ForEach(ctx context.Context, workers, n int, fn func(ctx context.Context, i int)) {
+	if workers < 1 {
+		workers = 1
+	}
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		select {
+		case <-ctx.Done():
+			continue
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(ctx, i)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestForEach(t *testing.T) {
+	var running, peak, total int32
+	ForEach(context.Background(), 3, 20, func(ctx context.Context, i int) {
+		cur := atomic.AddInt32(&running, 1)
+		for {
+			old := atomic.LoadInt32(&peak)
+			if cur <= old || atomic.CompareAndSwapInt32(&peak, old, cur) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		atomic.AddInt32(&total, 1)
+	})
+	if total != 20 || peak > 3 {
+		t.Fatalf("total: %d, peak: %d", total, peak)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	total = 0
+	ForEach(ctx, 3, 20, func(ctx context.Context, i int) { atomic.AddInt32(&total, 1) })
+	if total != 0 {
+		t.Fatalf("expect nothing started after cancel, got %d", total)
+	}
+}
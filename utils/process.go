@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const exitPollInterval = 200 * time.Millisecond
+
+// GetAppPIDs returns the pids of the processes of appName as the platform
+// names them, other processes containing the name do not match.
+func GetAppPIDs(ctx context.Context, appName string) ([]int, error) {
+	return CurrentPlatform().PIDs(ctx, appName)
+}
+
+func parsePIDs(out []string, err error) ([]int, error) {
+	if err != nil {
+		return nil, err
+	}
+	pids := make([]int, 0)
+	for _, v := range out {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		pid, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("parse pid failed: %w", err)
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
+
+// ProcessAlive reports whether pid is still in the process table.
+func ProcessAlive(pid int) bool {
+	return CurrentPlatform().Alive(pid)
+}
+
+// processExists reports whether pid is in the process table of a unix kernel.
+func processExists(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+func alivePIDs(pids []int) []int {
+	alive := make([]int, 0)
+	for _, pid := range pids {
+		if ProcessAlive(pid) {
+			alive = append(alive, pid)
+		}
+	}
+	return alive
+}
+
+// WaitExit polls pids until they all exited or timeout passed, it returns the
+// pids still alive.
+func WaitExit(ctx context.Context, pids []int, timeout time.Duration) []int {
+	deadline := time.Now().Add(timeout)
+	alive := alivePIDs(pids)
+	for len(alive) > 0 && time.Now().Before(deadline) {
+		if Sleep(ctx, exitPollInterval) != nil {
+			break
+		}
+		alive = alivePIDs(alive)
+	}
+	return alive
+}
+
+// SignalPIDs sends sig to every pid, processes already gone are ignored.
+func SignalPIDs(pids []int, sig syscall.Signal) error {
+	for _, pid := range pids {
+		if err := CurrentPlatform().Signal(pid, sig); err != nil && !errors.Is(err, syscall.ESRCH) {
+			return fmt.Errorf("signal %d %v failed: %w", pid, sig, err)
+		}
+	}
+	return nil
+}
+
+// QuitAndWait asks appName to quit and waits up to timeout for it to exit.
+func QuitAndWait(ctx context.Context, appName string, timeout time.Duration) error {
+	pids, err := GetAppPIDs(ctx, appName)
+	if err != nil {
+		return err
+	}
+	if err := GracefulQuit(ctx, appName); err != nil {
+		return err
+	}
+	if alive := WaitExit(ctx, pids, timeout); len(alive) > 0 {
+		return fmt.Errorf("%s did not quit within %s, pids: %v", appName, timeout, alive)
+	}
+	return nil
+}
+
+// RunningApp is a foreground app as the platform lists it.
+type RunningApp struct {
+	Name      string
+	BundleID  string
+	DesktopID string
+	Path      string
+	PID       int
+}
+
+// RunningApps lists the apps that are not background only, with their bundle
+// identifier and pid.
+func RunningApps(ctx context.Context) ([]RunningApp, error) {
+	return CurrentPlatform().RunningApps(ctx)
+}
+
+func parseRunningApps(out string) ([]RunningApp, error) {
+	result := make([]RunningApp, 0)
+	for _, line := range strings.Split(out, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected process line: %q", line)
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(fields[2]))
+		if err != nil {
+			return nil, fmt.Errorf("parse pid failed: %w", err)
+		}
+		if fields[1] == "missing value" {
+			fields[1] = ""
+		}
+		result = append(result, RunningApp{Name: fields[0], BundleID: fields[1], PID: pid, Path: strings.TrimSuffix(fields[3], "/")})
+	}
+	return result, nil
+}
+
+// AppPathOfBundle returns where the app with bundleID is installed, a desktop
+// file ID on linux.
+func AppPathOfBundle(ctx context.Context, bundleID string) (string, error) {
+	return CurrentPlatform().AppPath(ctx, bundleID)
+}
+
+// GetBundlePIDs returns the pids of the running apps with bundleID.
+func GetBundlePIDs(ctx context.Context, bundleID string) ([]int, error) {
+	running, err := RunningApps(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pids := make([]int, 0)
+	for _, app := range running {
+		if app.BundleID == bundleID {
+			pids = append(pids, app.PID)
+		}
+	}
+	return pids, nil
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestWaitExit(t *testing.T) {
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Skip(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(done)
+	}()
+	pid := cmd.Process.Pid
+
+	if alive := WaitExit(context.Background(), []int{pid}, 300*time.Millisecond); len(alive) != 1 {
+		t.Fatalf("sleep should still be alive, got %v", alive)
+	}
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if alive := WaitExit(context.Background(), []int{pid}, time.Second); len(alive) != 0 {
+		t.Fatalf("sleep should have exited, got %v", alive)
+	}
+}
+
+func TestParseRunningApps(t *testing.T) {
+	out := "访达\tcom.apple.finder\t412\t/System/Library/CoreServices/Finder.app/\n" +
+		"Helper\tmissing value\t99\t\n\n"
+	running, err := parseRunningApps(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(running) != 2 {
+		t.Fatalf("expect 2 apps, got %+v", running)
+	}
+	if running[0] != (RunningApp{Name: "访达", BundleID: "com.apple.finder", Path: "/System/Library/CoreServices/Finder.app", PID: 412}) {
+		t.Fatalf("unexpected app: %+v", running[0])
+	}
+	if running[1].BundleID != "" || running[1].PID != 99 {
+		t.Fatalf("missing bundle id must be empty: %+v", running[1])
+	}
+	if _, err := parseRunningApps("Finder\tcom.apple.finder\n"); err == nil {
+		t.Fatal("expect an error for a short line")
+	}
+}
@@ -0,0 +1,134 @@
+package wm
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Fake is an in-memory Backend for tests. Its windows are the ones Added and
+// the ones Source lists, e.g. the windows of a fake desktop, every move is
+// kept by window ID and the calls are recorded.
+type Fake struct {
+	Source func() []WindowInfo
+
+	mu       sync.Mutex
+	windows  []WindowInfo
+	spaceOf  map[int]int
+	frameOf  map[int]Rect
+	stateOf  map[int]WindowState
+	displays []int
+	spaces   []int
+	focused  int
+	calls    []string
+}
+
+// NewFake returns a fake with one display and spaces 1 to spaces.
+func NewFake(spaces int) *Fake {
+	f := &Fake{spaceOf: make(map[int]int), frameOf: make(map[int]Rect), stateOf: make(map[int]WindowState), displays: []int{1}}
+	for i := 1; i <= spaces; i++ {
+		f.spaces = append(f.spaces, i)
+	}
+	return f
+}
+
+// Add opens win, unplaced windows are on space and display 1.
+func (f *Fake) Add(win WindowInfo) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.windows = append(f.windows, win)
+}
+
+// Window returns window id as QueryWindows reports it.
+func (f *Fake) Window(id int) (WindowInfo, bool) {
+	windows, _ := f.QueryWindows(context.Background())
+	for _, win := range windows {
+		if win.WindowID == id {
+			return win, true
+		}
+	}
+	return WindowInfo{}, false
+}
+
+// Focused returns the window focused last, 0 if none.
+func (f *Fake) Focused() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.focused
+}
+
+// Calls returns the changes asked for, e.g. "space 4 2".
+func (f *Fake) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.calls)
+}
+
+func (f *Fake) Name() string { return "fake" }
+
+func (f *Fake) PreCheck() error { return nil }
+
+func (f *Fake) QueryWindows(context.Context) ([]WindowInfo, error) {
+	f.mu.Lock()
+	windows := slices.Clone(f.windows)
+	f.mu.Unlock()
+	if f.Source != nil {
+		windows = append(windows, f.Source()...)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, win := range windows {
+		if space, ok := f.spaceOf[win.WindowID]; ok {
+			win.SpaceID = space
+		}
+		if frame, ok := f.frameOf[win.WindowID]; ok {
+			win.Frame = frame
+		}
+		if state, ok := f.stateOf[win.WindowID]; ok {
+			win.State = &state
+		}
+		win.SpaceID, win.DisplayID = max(win.SpaceID, 1), max(win.DisplayID, 1)
+		windows[i] = win
+	}
+	return windows, nil
+}
+
+func (f *Fake) Displays(context.Context) ([]int, error) { return slices.Clone(f.displays), nil }
+
+func (f *Fake) Spaces(context.Context) ([]int, error) { return slices.Clone(f.spaces), nil }
+
+func (f *Fake) MoveToSpace(_ context.Context, id int, space int) error {
+	if !slices.Contains(f.spaces, space) {
+		return fmt.Errorf("space %d not found", space)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, fmt.Sprintf("space %d %d", id, space))
+	f.spaceOf[id] = space
+	return nil
+}
+
+func (f *Fake) SetFrame(_ context.Context, id int, frame Rect) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, fmt.Sprintf("frame %d %v", id, frame))
+	f.frameOf[id] = frame
+	return nil
+}
+
+func (f *Fake) Focus(_ context.Context, id int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, fmt.Sprintf("focus %d", id))
+	f.focused = id
+	return nil
+}
+
+func (f *Fake) SetState(_ context.Context, id int, state WindowState) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, fmt.Sprintf("state %d %+v", id, state))
+	f.stateOf[id] = state
+	return nil
+}
@@ -0,0 +1,264 @@
+package wm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Hyprland drives Hyprland over its request socket. Windows are addressed by
+// their address, spaces are workspace IDs and displays the monitor IDs plus
+// one.
+type Hyprland struct {
+	client *HyprlandClient
+}
+
+func NewHyprland() *Hyprland {
+	return &Hyprland{client: NewHyprlandClient()}
+}
+
+func (h *Hyprland) Name() string { return BackendHyprland }
+
+func (h *Hyprland) PreCheck() error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultHyprlandTimeout)
+	defer cancel()
+	if _, err := h.client.Send(ctx, "j/version"); err != nil {
+		return fmt.Errorf("hyprland is not reachable: %w", err)
+	}
+	return nil
+}
+
+// hyprFullscreen is a bool before Hyprland 0.42, the fullscreen mode since.
+type hyprFullscreen int
+
+func (f *hyprFullscreen) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case "true":
+		*f = 1
+	case "false", "null":
+		*f = 0
+	default:
+		n, err := strconv.Atoi(string(data))
+		if err != nil {
+			return fmt.Errorf("unexpected fullscreen %s", data)
+		}
+		*f = hyprFullscreen(n)
+	}
+	return nil
+}
+
+// hyprClient is a window as j/clients reports it.
+type hyprClient struct {
+	Address   string `json:"address"`
+	Mapped    bool   `json:"mapped"`
+	At        [2]int `json:"at"`
+	Size      [2]int `json:"size"`
+	Workspace struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"workspace"`
+	Floating   bool           `json:"floating"`
+	Monitor    int            `json:"monitor"`
+	Class      string         `json:"class"`
+	Title      string         `json:"title"`
+	PID        int            `json:"pid"`
+	Fullscreen hyprFullscreen `json:"fullscreen"`
+}
+
+// hyprAddress parses the hex address of a window into its WindowID.
+func hyprAddress(address string) (int, error) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(address, "0x"), 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected window address %q", address)
+	}
+	return int(id), nil
+}
+
+// info maps c onto a WindowInfo, the windows of a special workspace are
+// hidden and reported as minimized.
+func (c *hyprClient) info() (WindowInfo, error) {
+	id, err := hyprAddress(c.Address)
+	if err != nil {
+		return WindowInfo{}, err
+	}
+	info := WindowInfo{
+		App:       c.Class,
+		Title:     c.Title,
+		Frame:     Rect{X: float64(c.At[0]), Y: float64(c.At[1]), W: float64(c.Size[0]), H: float64(c.Size[1])},
+		DisplayID: c.Monitor + 1,
+		WindowID:  id,
+		Pid:       c.PID,
+		State:     &WindowState{Floating: c.Floating, Fullscreen: c.Fullscreen != 0},
+	}
+	if c.Workspace.ID > 0 {
+		info.SpaceID = c.Workspace.ID
+	} else {
+		info.State.Minimized = strings.HasPrefix(c.Workspace.Name, "special")
+	}
+	return info, nil
+}
+
+func (h *Hyprland) query(ctx context.Context, request string, v any) error {
+	output, err := h.client.Send(ctx, request)
+	if err != nil {
+		return fmt.Errorf("hyprland %s failed: %w", request, err)
+	}
+	if err := json.Unmarshal(output, v); err != nil {
+		return fmt.Errorf("unmarshal failed: %w", err)
+	}
+	return nil
+}
+
+// dispatch runs dispatcher on window id, the window is its last argument.
+func (h *Hyprland) dispatch(ctx context.Context, id int, dispatcher string, args ...string) error {
+	window := fmt.Sprintf("address:0x%x", id)
+	return h.command(ctx, "dispatch "+dispatcher+" "+strings.Join(append(args, window), ","))
+}
+
+// command sends request, Hyprland replies "ok" or why it refused.
+func (h *Hyprland) command(ctx context.Context, request string) error {
+	output, err := h.client.Send(ctx, request)
+	if err != nil {
+		return err
+	}
+	if reply := strings.TrimSpace(string(output)); reply != "ok" {
+		return fmt.Errorf("hyprland %s failed: %s", request, reply)
+	}
+	return nil
+}
+
+func (h *Hyprland) QueryWindows(ctx context.Context) ([]WindowInfo, error) {
+	var clients []hyprClient
+	if err := h.query(ctx, "j/clients", &clients); err != nil {
+		return nil, err
+	}
+	windows := make([]WindowInfo, 0, len(clients))
+	for i := range clients {
+		if !clients[i].Mapped {
+			continue
+		}
+		info, err := clients[i].info()
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, info)
+	}
+	return windows, nil
+}
+
+func (h *Hyprland) window(ctx context.Context, id int) (*WindowInfo, error) {
+	windows, err := h.QueryWindows(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, win := range windows {
+		if win.WindowID == id {
+			return &win, nil
+		}
+	}
+	return nil, fmt.Errorf("window 0x%x not found", id)
+}
+
+func (h *Hyprland) Displays(ctx context.Context) ([]int, error) {
+	var monitors []struct {
+		ID int `json:"id"`
+	}
+	if err := h.query(ctx, "j/monitors", &monitors); err != nil {
+		return nil, err
+	}
+	displays := make([]int, 0, len(monitors))
+	for _, monitor := range monitors {
+		displays = append(displays, monitor.ID+1)
+	}
+	return displays, nil
+}
+
+// hyprWorkspace is a workspace as j/workspaces reports it, special ones have
+// a negative ID.
+type hyprWorkspace struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Spaces is nil, Hyprland removes a workspace once its last window is gone
+// and creates it again when a window is moved to it.
+func (h *Hyprland) Spaces(context.Context) ([]int, error) {
+	return nil, nil
+}
+
+func (h *Hyprland) MoveToSpace(ctx context.Context, id int, space int) error {
+	if space <= 0 {
+		return fmt.Errorf("window 0x%x was not on a regular workspace", id)
+	}
+	return h.dispatch(ctx, id, "movetoworkspacesilent", strconv.Itoa(space))
+}
+
+// SetFrame only places floating windows, a tiled one takes the place its
+// layout gives.
+func (h *Hyprland) SetFrame(ctx context.Context, id int, frame Rect) error {
+	win, err := h.window(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !win.State.Floating {
+		return nil
+	}
+	return errors.Join(
+		h.dispatch(ctx, id, "movewindowpixel", fmt.Sprintf("exact %d %d", int(frame.X), int(frame.Y))),
+		h.dispatch(ctx, id, "resizewindowpixel", fmt.Sprintf("exact %d %d", int(frame.W), int(frame.H))))
+}
+
+func (h *Hyprland) Focus(ctx context.Context, id int) error {
+	return h.dispatch(ctx, id, "focuswindow")
+}
+
+// SetState changes what differs from state. A minimized window goes to the
+// special workspace, one still there when it is shown again was saved on no
+// regular workspace and comes to the active one. Fullscreen only applies to
+// the focused window, so the window is focused first.
+func (h *Hyprland) SetState(ctx context.Context, id int, state WindowState) error {
+	win, err := h.window(ctx, id)
+	if err != nil {
+		return err
+	}
+	cur := win.State
+	if state.Minimized {
+		if cur.Minimized {
+			return nil
+		}
+		return h.dispatch(ctx, id, "movetoworkspacesilent", "special")
+	}
+	if cur.Minimized {
+		var active hyprWorkspace
+		if err := h.query(ctx, "j/activeworkspace", &active); err != nil {
+			return err
+		}
+		if err := h.dispatch(ctx, id, "movetoworkspacesilent", strconv.Itoa(active.ID)); err != nil {
+			return err
+		}
+	}
+	if cur.Floating != state.Floating {
+		if err := h.dispatch(ctx, id, "togglefloating"); err != nil {
+			return err
+		}
+	}
+	if cur.Fullscreen != state.Fullscreen {
+		if err := h.Focus(ctx, id); err != nil {
+			return err
+		}
+		if err := h.command(ctx, "dispatch fullscreen 0"); err != nil {
+			return err
+		}
+		win, err := h.window(ctx, id)
+		if err != nil {
+			return err
+		}
+		if win.State.Fullscreen != state.Fullscreen {
+			return fmt.Errorf("window 0x%x lost the focus before its fullscreen changed", id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package wm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const defaultHyprlandTimeout = 5 * time.Second
+
+// HyprlandClient sends requests, e.g. "j/clients" or "dispatch ...", over the
+// request socket of Hyprland, one connection each as hyprctl does.
+type HyprlandClient struct {
+	Path    string
+	Timeout time.Duration
+}
+
+// NewHyprlandClient returns a client of the instance named by
+// $HYPRLAND_INSTANCE_SIGNATURE, its socket is in $XDG_RUNTIME_DIR/hypr, or in
+// /tmp/hypr before Hyprland 0.40.
+func NewHyprlandClient() *HyprlandClient {
+	c := &HyprlandClient{Timeout: defaultHyprlandTimeout}
+	signature := os.Getenv("HYPRLAND_INSTANCE_SIGNATURE")
+	if signature == "" {
+		return c
+	}
+	c.Path = filepath.Join(os.Getenv("XDG_RUNTIME_DIR"), "hypr", signature, ".socket.sock")
+	if _, err := os.Stat(c.Path); err != nil {
+		c.Path = filepath.Join("/tmp/hypr", signature, ".socket.sock")
+	}
+	return c
+}
+
+// Send sends request and returns the reply of Hyprland.
+func (c *HyprlandClient) Send(ctx context.Context, request string) ([]byte, error) {
+	if c.Path == "" {
+		return nil, errors.New("HYPRLAND_INSTANCE_SIGNATURE is not set, is Hyprland running?")
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", c.Path)
+	if err != nil {
+		return nil, fmt.Errorf("connect to Hyprland failed: %w", err)
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(c.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetDeadline(deadline)
+
+	if _, err := io.WriteString(conn, request); err != nil {
+		return nil, fmt.Errorf("send to Hyprland failed: %w", err)
+	}
+	out, err := io.ReadAll(conn)
+	if err != nil {
+		return nil, fmt.Errorf("read from Hyprland failed: %w", err)
+	}
+	return out, nil
+}
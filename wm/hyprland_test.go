@@ -0,0 +1,309 @@
+package wm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeHyprland stands in for the request socket of Hyprland: it replays the
+// queries recorded in testdata/hyprland, except j/clients it serves from the
+// clients the dispatches were applied to, and records the dispatches.
+type fakeHyprland struct {
+	mu         sync.Mutex
+	clients    []hyprClient
+	focused    string
+	dispatches []string
+}
+
+func newFakeHyprland(t *testing.T) (*fakeHyprland, *Hyprland) {
+	f := &fakeHyprland{}
+	clients, err := os.ReadFile(filepath.Join("testdata", "hyprland", "clients.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(clients, &f.clients); err != nil {
+		t.Fatal(err)
+	}
+	// a socket path is limited to about 100 bytes, t.TempDir may be longer
+	dir, err := os.MkdirTemp("", "hypr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	path := filepath.Join(dir, ".socket.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			f.handle(conn)
+		}
+	}()
+	return f, &Hyprland{client: &HyprlandClient{Path: path, Timeout: time.Second}}
+}
+
+func (f *fakeHyprland) handle(conn net.Conn) {
+	defer conn.Close()
+	// Hyprland reads a request at once
+	buf := make([]byte, 8192)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return
+	}
+	request := string(buf[:n])
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var reply []byte
+	switch {
+	case request == "j/version":
+		reply = []byte(`{"branch": "", "commit": "", "tag": "v0.41.2"}`)
+	case request == "j/clients":
+		reply, _ = json.Marshal(f.clients)
+	case strings.HasPrefix(request, "j/"):
+		reply, _ = os.ReadFile(filepath.Join("testdata", "hyprland", strings.TrimPrefix(request, "j/")+".json"))
+	case strings.HasPrefix(request, "dispatch "):
+		f.dispatches = append(f.dispatches, strings.TrimPrefix(request, "dispatch "))
+		reply = []byte("ok")
+		if err := f.apply(strings.TrimPrefix(request, "dispatch ")); err != nil {
+			reply = []byte(err.Error())
+		}
+	default:
+		reply = []byte("unknown request")
+	}
+	_, _ = conn.Write(reply)
+}
+
+// apply runs dispatch on the window of its address, or the focused one.
+func (f *fakeHyprland) apply(dispatch string) error {
+	dispatcher, args, _ := strings.Cut(dispatch, " ")
+	address := f.focused
+	if i := strings.LastIndex(args, "address:"); i >= 0 {
+		address, args = args[i+len("address:"):], strings.TrimSuffix(args[:i], ",")
+	}
+	var c *hyprClient
+	for i := range f.clients {
+		if f.clients[i].Address == address {
+			c = &f.clients[i]
+		}
+	}
+	if c == nil {
+		return errors.New("No such window found")
+	}
+	switch dispatcher {
+	case "movetoworkspacesilent":
+		if args == "special" {
+			c.Workspace.ID, c.Workspace.Name = -99, "special:special"
+			break
+		}
+		id, err := strconv.Atoi(args)
+		if err != nil {
+			return fmt.Errorf("invalid workspace %q", args)
+		}
+		c.Workspace.ID, c.Workspace.Name = id, args
+	case "togglefloating":
+		c.Floating = !c.Floating
+	case "focuswindow":
+		f.focused = address
+	case "fullscreen":
+		if c.Fullscreen == 0 {
+			c.Fullscreen = 2
+		} else {
+			c.Fullscreen = 0
+		}
+	case "movewindowpixel":
+		_, err := fmt.Sscanf(args, "exact %d %d", &c.At[0], &c.At[1])
+		return err
+	case "resizewindowpixel":
+		_, err := fmt.Sscanf(args, "exact %d %d", &c.Size[0], &c.Size[1])
+		return err
+	default:
+		return fmt.Errorf("invalid dispatcher %s", dispatcher)
+	}
+	return nil
+}
+
+func (f *fakeHyprland) dispatched() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.dispatches
+}
+
+func TestHyprlandQuery(t *testing.T) {
+	_, h := newFakeHyprland(t)
+	if err := h.PreCheck(); err != nil {
+		t.Fatal(err)
+	}
+	windows, err := h.QueryWindows(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []WindowInfo{
+		{App: "kitty", Title: "~/src/api", Frame: Rect{X: 10, Y: 50, W: 1260, H: 1380}, SpaceID: 1, DisplayID: 1, WindowID: 0x5581f0a3c2b0, Pid: 1650, State: &WindowState{}},
+		{App: "Slack", Title: "general - Slack", Frame: Rect{X: 700, Y: 300, W: 900, H: 600}, SpaceID: 2, DisplayID: 1, WindowID: 0x5581f0b71c40, Pid: 1788, State: &WindowState{Floating: true}},
+		{App: "firefox", Title: "Mozilla Firefox", Frame: Rect{X: 2560, W: 1920, H: 1080}, SpaceID: 4, DisplayID: 2, WindowID: 0x5581f0c9e8a0, Pid: 1901, State: &WindowState{Fullscreen: true}},
+		{App: "org.pulseaudio.pavucontrol", Title: "Volume Control", Frame: Rect{X: 660, Y: 290, W: 600, H: 500}, DisplayID: 1, WindowID: 0x5581f0d10470, Pid: 2210, State: &WindowState{Floating: true, Minimized: true}},
+	}
+	if !reflect.DeepEqual(windows, want) {
+		got, _ := json.Marshal(windows)
+		t.Fatalf("windows:\n%s", got)
+	}
+	if displays, err := h.Displays(context.Background()); err != nil || !reflect.DeepEqual(displays, []int{1, 2}) {
+		t.Fatalf("displays: %v %v", displays, err)
+	}
+	if spaces, err := h.Spaces(context.Background()); err != nil || spaces != nil {
+		t.Fatalf("spaces: %v %v", spaces, err)
+	}
+
+	var before struct {
+		Fullscreen hyprFullscreen `json:"fullscreen"`
+	}
+	if err := json.Unmarshal([]byte(`{"fullscreen": true}`), &before); err != nil || before.Fullscreen != 1 {
+		t.Fatalf("the fullscreen bool of Hyprland before 0.42: %v %v", before.Fullscreen, err)
+	}
+}
+
+func TestHyprlandRestore(t *testing.T) {
+	f, h := newFakeHyprland(t)
+	m := NewManager(h)
+	ctx := context.Background()
+	if err := m.TakeSnapshot(ctx); err != nil {
+		t.Fatal(err)
+	}
+	saved := []WindowInfo{
+		// floating on workspace 3
+		{App: "kitty", Frame: Rect{X: 100, Y: 80, W: 800, H: 600}, SpaceID: 3, State: &WindowState{Floating: true}},
+		// back from the special workspace, still floating
+		{App: "org.pulseaudio.pavucontrol", Frame: Rect{X: 5, Y: 5, W: 300, H: 200}, SpaceID: 2, State: &WindowState{Floating: true}},
+		{App: "firefox", SpaceID: 4, State: &WindowState{Minimized: true}},
+	}
+	for i := range saved {
+		if err := m.RestoreWindow(ctx, &saved[i], nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{
+		"movetoworkspacesilent 3,address:0x5581f0a3c2b0",
+		"togglefloating address:0x5581f0a3c2b0",
+		"movewindowpixel exact 100 80,address:0x5581f0a3c2b0",
+		"resizewindowpixel exact 800 600,address:0x5581f0a3c2b0",
+		"movetoworkspacesilent 2,address:0x5581f0d10470",
+		"movewindowpixel exact 5 5,address:0x5581f0d10470",
+		"resizewindowpixel exact 300 200,address:0x5581f0d10470",
+		"movetoworkspacesilent 4,address:0x5581f0c9e8a0",
+		"movetoworkspacesilent special,address:0x5581f0c9e8a0",
+	}
+	if got := f.dispatched(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("dispatches:\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	windows, err := h.QueryWindows(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, win := range saved {
+		got := windows[slices.IndexFunc(windows, func(w WindowInfo) bool { return w.App == win.App })]
+		if win.State.Minimized {
+			win.SpaceID = 0
+		}
+		if got.SpaceID != win.SpaceID || got.State.Floating != win.State.Floating || got.State.Minimized != win.State.Minimized ||
+			(win.State.Floating && got.Frame != win.Frame) {
+			t.Fatalf("window %d restored as %+v %+v", i, got, got.State)
+		}
+	}
+
+	// shown again without a saved workspace, fullscreen left on the focused
+	// window
+	if err := h.Focus(ctx, 0x5581f0a3c2b0); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.SetState(ctx, 0x5581f0c9e8a0, WindowState{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.dispatched()[len(want)+1:]; !reflect.DeepEqual(got, []string{
+		"movetoworkspacesilent 1,address:0x5581f0c9e8a0",
+		"focuswindow address:0x5581f0c9e8a0",
+		"fullscreen 0",
+	}) {
+		t.Fatalf("shown on the active workspace: %q", got)
+	}
+	firefox, err := h.window(ctx, 0x5581f0c9e8a0)
+	if err != nil || firefox.SpaceID != 1 || firefox.State.Fullscreen {
+		t.Fatalf("firefox: %+v %v", firefox, err)
+	}
+	if kitty, err := h.window(ctx, 0x5581f0a3c2b0); err != nil || kitty.State.Fullscreen {
+		t.Fatalf("fullscreen applies to the window given: %+v %v", kitty, err)
+	}
+	if err := h.MoveToSpace(ctx, 42, 1); err == nil || !strings.Contains(err.Error(), "No such window found") {
+		t.Fatalf("expect the reply of Hyprland, got %v", err)
+	}
+}
+
+func TestHyprlandEmptyWorkspace(t *testing.T) {
+	f, h := newFakeHyprland(t)
+	ctx := context.Background()
+	var workspaces []hyprWorkspace
+	if err := h.query(ctx, "j/workspaces", &workspaces); err != nil {
+		t.Fatal(err)
+	}
+	if slices.ContainsFunc(workspaces, func(w hyprWorkspace) bool { return w.ID == 3 }) {
+		t.Fatal("workspace 3 is expected to be empty, Hyprland does not list it")
+	}
+	// the preflight does not check spaces it is given none of
+	if spaces, err := h.Spaces(ctx); err != nil || spaces != nil {
+		t.Fatalf("spaces: %v %v", spaces, err)
+	}
+
+	m := NewManager(h)
+	if err := m.TakeSnapshot(ctx); err != nil {
+		t.Fatal(err)
+	}
+	saved := WindowInfo{App: "firefox", SpaceID: 3, State: &WindowState{}}
+	if err := m.RestoreWindow(ctx, &saved, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.dispatched(); len(got) == 0 || got[0] != "movetoworkspacesilent 3,address:0x5581f0c9e8a0" {
+		t.Fatalf("dispatches: %q", got)
+	}
+	if firefox, err := h.window(ctx, 0x5581f0c9e8a0); err != nil || firefox.SpaceID != 3 {
+		t.Fatalf("firefox: %+v %v", firefox, err)
+	}
+}
+
+func TestHyprlandClientPath(t *testing.T) {
+	t.Setenv("HYPRLAND_INSTANCE_SIGNATURE", "")
+	if _, err := NewHyprlandClient().Send(context.Background(), "j/clients"); err == nil {
+		t.Fatal("expect an error outside of Hyprland")
+	}
+	runtime := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", runtime)
+	t.Setenv("HYPRLAND_INSTANCE_SIGNATURE", "abc_123")
+	if path := NewHyprlandClient().Path; path != "/tmp/hypr/abc_123/.socket.sock" {
+		t.Fatalf("socket before 0.40: %s", path)
+	}
+	if err := os.MkdirAll(filepath.Join(runtime, "hypr", "abc_123"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(runtime, "hypr", "abc_123", ".socket.sock"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if path := NewHyprlandClient().Path; path != filepath.Join(runtime, "hypr", "abc_123", ".socket.sock") {
+		t.Fatalf("socket: %s", path)
+	}
+}
@@ -0,0 +1,287 @@
+package wm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// i3Scratchpad is the workspace holding the windows moved to the scratchpad,
+// they are reported as minimized.
+const i3Scratchpad = "__i3_scratch"
+
+// I3 drives i3 or sway over their IPC socket. Spaces are workspace numbers,
+// workspaces without number are moved to by name, and displays the outputs in
+// the order of the tree.
+type I3 struct {
+	client *I3Client
+}
+
+func NewI3() *I3 {
+	return &I3{client: NewI3Client()}
+}
+
+func (b *I3) Name() string { return BackendI3 }
+
+func (b *I3) PreCheck() error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultI3Timeout)
+	defer cancel()
+	if _, err := b.client.Send(ctx, i3GetVersion, ""); err != nil {
+		return fmt.Errorf("i3 or sway is not reachable: %w", err)
+	}
+	return nil
+}
+
+type i3Rect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// i3Node is a container of the tree, the fields of i3 and sway alike.
+type i3Node struct {
+	ID               int     `json:"id"`
+	Type             string  `json:"type"`
+	Name             string  `json:"name"`
+	Num              int     `json:"num"`
+	Layout           string  `json:"layout"`
+	Rect             i3Rect  `json:"rect"`
+	AppID            *string `json:"app_id"`
+	PID              int     `json:"pid"`
+	Window           *int    `json:"window"`
+	WindowProperties *struct {
+		Class string `json:"class"`
+	} `json:"window_properties"`
+	Floating       string   `json:"floating"`
+	FullscreenMode int      `json:"fullscreen_mode"`
+	Nodes          []i3Node `json:"nodes"`
+	FloatingNodes  []i3Node `json:"floating_nodes"`
+}
+
+// isWindow reports whether n holds a window: an X11 window on i3 and
+// Xwayland, an app_id or pid on sway.
+func (n *i3Node) isWindow() bool {
+	if len(n.Nodes) > 0 || len(n.FloatingNodes) > 0 || (n.Type != "con" && n.Type != "floating_con") {
+		return false
+	}
+	return n.Window != nil || n.AppID != nil || n.PID > 0
+}
+
+// app is the app_id of a Wayland window, or the class of an X11 one.
+func (n *i3Node) app() string {
+	if n.AppID != nil && *n.AppID != "" {
+		return *n.AppID
+	}
+	if n.WindowProperties != nil {
+		return n.WindowProperties.Class
+	}
+	return ""
+}
+
+func (n *i3Node) info(display int, workspace *i3Node, layout string, floating bool) WindowInfo {
+	info := WindowInfo{
+		App:       n.app(),
+		Title:     n.Name,
+		Frame:     Rect{X: float64(n.Rect.X), Y: float64(n.Rect.Y), W: float64(n.Rect.Width), H: float64(n.Rect.Height)},
+		DisplayID: display,
+		WindowID:  n.ID,
+		Pid:       n.PID,
+		State: &WindowState{
+			Floating:   floating || strings.HasSuffix(n.Floating, "_on"),
+			Fullscreen: n.FullscreenMode != 0,
+		},
+	}
+	if workspace != nil {
+		if workspace.Name == i3Scratchpad {
+			info.State.Minimized = true
+		} else if workspace.Num > 0 {
+			info.SpaceID = workspace.Num
+		} else {
+			info.SpaceName = workspace.Name
+		}
+	}
+	if !info.State.Floating {
+		info.State.Layout = layout
+	}
+	return info
+}
+
+// i3Windows lists the windows of the tree under root, every one with the
+// layout of the container holding it.
+func i3Windows(root *i3Node) []WindowInfo {
+	windows := make([]WindowInfo, 0)
+	var visit func(n *i3Node, display int, workspace *i3Node, layout string, floating bool)
+	visit = func(n *i3Node, display int, workspace *i3Node, layout string, floating bool) {
+		if n.Type == "workspace" {
+			workspace = n
+		}
+		if n.isWindow() {
+			windows = append(windows, n.info(display, workspace, layout, floating))
+			return
+		}
+		for i := range n.Nodes {
+			visit(&n.Nodes[i], display, workspace, n.Layout, floating)
+		}
+		for i := range n.FloatingNodes {
+			visit(&n.FloatingNodes[i], display, workspace, n.Layout, true)
+		}
+	}
+	display := 0
+	for i := range root.Nodes {
+		output := &root.Nodes[i]
+		// __i3 is the output of the scratchpad, not a display
+		if output.Name == "__i3" {
+			visit(output, 0, nil, "", false)
+			continue
+		}
+		display++
+		visit(output, display, nil, "", false)
+	}
+	return windows
+}
+
+func (b *I3) tree(ctx context.Context) (*i3Node, error) {
+	output, err := b.client.Send(ctx, i3GetTree, "")
+	if err != nil {
+		return nil, fmt.Errorf("i3 get tree failed: %w", err)
+	}
+	var root i3Node
+	if err := json.Unmarshal(output, &root); err != nil {
+		return nil, fmt.Errorf("unmarshal failed: %w", err)
+	}
+	return &root, nil
+}
+
+// run runs cmd on container id.
+func (b *I3) run(ctx context.Context, id int, cmd string) error {
+	cmd = fmt.Sprintf("[con_id=%d] %s", id, cmd)
+	output, err := b.client.Send(ctx, i3RunCommand, cmd)
+	if err != nil {
+		return err
+	}
+	var results []i3CommandResult
+	if err := json.Unmarshal(output, &results); err != nil {
+		return fmt.Errorf("unmarshal failed: %w", err)
+	}
+	return checkI3Commands(cmd, results)
+}
+
+func (b *I3) window(ctx context.Context, id int) (*WindowInfo, error) {
+	windows, err := b.QueryWindows(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, win := range windows {
+		if win.WindowID == id {
+			return &win, nil
+		}
+	}
+	return nil, fmt.Errorf("window %d not found", id)
+}
+
+func (b *I3) QueryWindows(ctx context.Context) ([]WindowInfo, error) {
+	root, err := b.tree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return i3Windows(root), nil
+}
+
+func (b *I3) Displays(ctx context.Context) ([]int, error) {
+	root, err := b.tree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	displays := make([]int, 0)
+	for _, output := range root.Nodes {
+		if output.Name != "__i3" {
+			displays = append(displays, len(displays)+1)
+		}
+	}
+	return displays, nil
+}
+
+// Spaces is nil, i3 creates a workspace when a window is moved to it.
+func (b *I3) Spaces(context.Context) ([]int, error) {
+	return nil, nil
+}
+
+func (b *I3) MoveToSpace(ctx context.Context, id int, space int) error {
+	if space <= 0 {
+		return fmt.Errorf("window %d was not on a numbered workspace", id)
+	}
+	return b.run(ctx, id, fmt.Sprintf("move container to workspace number %d", space))
+}
+
+// MoveToNamedSpace moves window id to the workspace called name, i3 creates it
+// when it is missing.
+func (b *I3) MoveToNamedSpace(ctx context.Context, id int, name string) error {
+	return b.run(ctx, id, "move container to workspace "+i3Quote(name))
+}
+
+// i3Quote quotes s as an argument of a command.
+func i3Quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// SetFrame only places floating windows, a tiled one takes the place its
+// layout gives.
+func (b *I3) SetFrame(ctx context.Context, id int, frame Rect) error {
+	win, err := b.window(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !win.State.Floating {
+		return nil
+	}
+	return b.run(ctx, id, fmt.Sprintf("move absolute position %d px %d px, resize set %d px %d px",
+		int(frame.X), int(frame.Y), int(frame.W), int(frame.H)))
+}
+
+func (b *I3) Focus(ctx context.Context, id int) error {
+	return b.run(ctx, id, "focus")
+}
+
+func i3Switch(cmd string, on bool) string {
+	if on {
+		return cmd + " enable"
+	}
+	return cmd + " disable"
+}
+
+// SetState changes what differs from state in one command. A minimized
+// window goes to the scratchpad, a tiled one gets the layout of the container
+// it was in. The nesting of split containers is not recreated, the layout is
+// the one of the container i3 moved the window into.
+func (b *I3) SetState(ctx context.Context, id int, state WindowState) error {
+	win, err := b.window(ctx, id)
+	if err != nil {
+		return err
+	}
+	cur := win.State
+	cmds := make([]string, 0, 3)
+	if state.Minimized {
+		if !cur.Minimized {
+			cmds = append(cmds, "move scratchpad")
+		}
+	} else {
+		if cur.Minimized {
+			cmds = append(cmds, "scratchpad show")
+		}
+		if cur.Floating != state.Floating {
+			cmds = append(cmds, i3Switch("floating", state.Floating))
+		}
+		if cur.Fullscreen != state.Fullscreen {
+			cmds = append(cmds, i3Switch("fullscreen", state.Fullscreen))
+		}
+		if !state.Floating && state.Layout != "" && state.Layout != cur.Layout {
+			cmds = append(cmds, "layout "+state.Layout)
+		}
+	}
+	if len(cmds) == 0 {
+		return nil
+	}
+	return b.run(ctx, id, strings.Join(cmds, ", "))
+}
@@ -0,0 +1,137 @@
+package wm
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// i3Magic starts every message of the i3 IPC protocol, sway speaks it too.
+const i3Magic = "i3-ipc"
+
+// i3 IPC message types.
+const (
+	i3RunCommand uint32 = 0
+	i3GetTree    uint32 = 4
+	i3GetVersion uint32 = 7
+)
+
+const defaultI3Timeout = 5 * time.Second
+
+// I3Client sends messages over the IPC socket of i3 or sway.
+type I3Client struct {
+	// Path is the socket, "" to look it up on first use.
+	Path    string
+	Timeout time.Duration
+
+	mu sync.Mutex
+}
+
+// NewI3Client returns a client of the socket named by $SWAYSOCK or $I3SOCK,
+// or else by `i3 --get-socketpath`.
+func NewI3Client() *I3Client {
+	path := os.Getenv("SWAYSOCK")
+	if path == "" {
+		path = os.Getenv("I3SOCK")
+	}
+	return &I3Client{Path: path, Timeout: defaultI3Timeout}
+}
+
+func (c *I3Client) socketPath(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Path != "" {
+		return c.Path, nil
+	}
+	output, err := exec.CommandContext(ctx, "i3", "--get-socketpath").Output()
+	if err != nil {
+		return "", fmt.Errorf("i3 socket not found, neither $SWAYSOCK nor $I3SOCK is set: %w", err)
+	}
+	c.Path = strings.TrimSpace(string(output))
+	return c.Path, nil
+}
+
+// encodeI3Message frames payload: the magic, then its length and type as
+// 32-bit little-endian ints.
+func encodeI3Message(typ uint32, payload string) []byte {
+	msg := make([]byte, 0, len(i3Magic)+8+len(payload))
+	msg = append(msg, i3Magic...)
+	msg = binary.LittleEndian.AppendUint32(msg, uint32(len(payload)))
+	msg = binary.LittleEndian.AppendUint32(msg, typ)
+	return append(msg, payload...)
+}
+
+// readI3Message reads one framed message and returns its type and payload.
+func readI3Message(r io.Reader) (uint32, []byte, error) {
+	header := make([]byte, len(i3Magic)+8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	if !bytes.HasPrefix(header, []byte(i3Magic)) {
+		return 0, nil, fmt.Errorf("not an i3 IPC message: %q", header)
+	}
+	n := binary.LittleEndian.Uint32(header[len(i3Magic):])
+	typ := binary.LittleEndian.Uint32(header[len(i3Magic)+4:])
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return typ, payload, nil
+}
+
+// Send sends one message and returns the payload of the reply.
+func (c *I3Client) Send(ctx context.Context, typ uint32, payload string) ([]byte, error) {
+	path, err := c.socketPath(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("connect to i3 failed: %w", err)
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(c.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetDeadline(deadline)
+
+	if _, err := conn.Write(encodeI3Message(typ, payload)); err != nil {
+		return nil, fmt.Errorf("send to i3 failed: %w", err)
+	}
+	replyType, reply, err := readI3Message(conn)
+	if err != nil {
+		return nil, fmt.Errorf("read from i3 failed: %w", err)
+	}
+	if replyType != typ {
+		return nil, fmt.Errorf("i3 replied with type %d to type %d", replyType, typ)
+	}
+	return reply, nil
+}
+
+// i3CommandResult is the outcome of one command of a RUN_COMMAND.
+type i3CommandResult struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error"`
+}
+
+// checkI3Commands returns the errors of the commands that failed in reply.
+func checkI3Commands(cmd string, results []i3CommandResult) error {
+	errs := make([]error, 0)
+	for _, result := range results {
+		if !result.Success {
+			errs = append(errs, fmt.Errorf("i3 command %q failed: %s", cmd, result.Error))
+		}
+	}
+	return errors.Join(errs...)
+}
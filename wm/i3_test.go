@@ -0,0 +1,349 @@
+package wm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeI3Server serves a tree recorded in testdata/i3 and applies the
+// commands run to it as i3 does, refusing the ones naming workspace 99.
+type fakeI3Server struct {
+	mu       sync.Mutex
+	root     i3Node
+	commands []string
+}
+
+func newFakeI3Server(t *testing.T, treeFile string) (*fakeI3Server, *I3) {
+	tree, err := os.ReadFile(filepath.Join("testdata", "i3", treeFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeI3Server{}
+	if err := json.Unmarshal(tree, &s.root); err != nil {
+		t.Fatal(err)
+	}
+	// a socket path is limited to about 100 bytes, t.TempDir may be longer
+	dir, err := os.MkdirTemp("", "i3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	path := filepath.Join(dir, "ipc.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.handle(t, conn)
+		}
+	}()
+	return s, &I3{client: &I3Client{Path: path, Timeout: time.Second}}
+}
+
+func (s *fakeI3Server) handle(t *testing.T, conn net.Conn) {
+	defer conn.Close()
+	typ, payload, err := readI3Message(conn)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var reply []byte
+	switch typ {
+	case i3GetTree:
+		reply, _ = json.Marshal(s.root)
+	case i3GetVersion:
+		reply = []byte(`{"major": 1, "minor": 9, "patch": 0, "human_readable": "1.9"}`)
+	case i3RunCommand:
+		s.commands = append(s.commands, string(payload))
+		result := i3CommandResult{Success: true}
+		if err := s.apply(string(payload)); err != nil {
+			result = i3CommandResult{Error: err.Error()}
+		}
+		reply, _ = json.Marshal([]i3CommandResult{result})
+	}
+	_, _ = conn.Write(encodeI3Message(typ, string(reply)))
+}
+
+func (s *fakeI3Server) ran() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commands
+}
+
+// find returns container id and the container holding it.
+func (n *i3Node) find(id int) (node, parent *i3Node) {
+	for _, children := range [][]i3Node{n.Nodes, n.FloatingNodes} {
+		for i := range children {
+			if children[i].ID == id {
+				return &children[i], n
+			}
+			if node, parent := children[i].find(id); node != nil {
+				return node, parent
+			}
+		}
+	}
+	return nil, nil
+}
+
+// workspace returns the workspace holding container id.
+func (n *i3Node) workspace(id int) *i3Node {
+	for _, children := range [][]i3Node{n.Nodes, n.FloatingNodes} {
+		for i := range children {
+			if children[i].ID == id {
+				return nil
+			}
+			if children[i].Type == "workspace" {
+				if node, _ := children[i].find(id); node != nil {
+					return &children[i]
+				}
+			} else if ws := children[i].workspace(id); ws != nil {
+				return ws
+			}
+		}
+	}
+	return nil
+}
+
+// detach takes container id out of the tree.
+func (s *fakeI3Server) detach(id int) i3Node {
+	_, parent := s.root.find(id)
+	for _, children := range []*[]i3Node{&parent.Nodes, &parent.FloatingNodes} {
+		for i := range *children {
+			if (*children)[i].ID == id {
+				node := (*children)[i]
+				*children = slices.Delete(*children, i, i+1)
+				return node
+			}
+		}
+	}
+	panic("unreachable")
+}
+
+// moveTo moves container id to the workspace matching, one is created on the
+// first display when none does.
+func (s *fakeI3Server) moveTo(id int, match func(*i3Node) bool, create i3Node) {
+	node := s.detach(id)
+	var ws *i3Node
+	for i := range s.root.Nodes {
+		for j := range s.root.Nodes[i].Nodes {
+			if match(&s.root.Nodes[i].Nodes[j]) {
+				ws = &s.root.Nodes[i].Nodes[j]
+			}
+		}
+	}
+	if ws == nil {
+		output := &s.root.Nodes[1]
+		create.ID, create.Type, create.Layout = 1000+len(output.Nodes), "workspace", "splith"
+		output.Nodes = append(output.Nodes, create)
+		ws = &output.Nodes[len(output.Nodes)-1]
+	}
+	if node.Type == "floating_con" {
+		ws.FloatingNodes = append(ws.FloatingNodes, node)
+	} else {
+		ws.Nodes = append(ws.Nodes, node)
+	}
+}
+
+func (s *fakeI3Server) apply(payload string) error {
+	criteria, cmds, _ := strings.Cut(strings.TrimPrefix(payload, "[con_id="), "] ")
+	id, err := strconv.Atoi(criteria)
+	if err != nil {
+		return fmt.Errorf("unexpected criteria in %q", payload)
+	}
+	if node, _ := s.root.find(id); node == nil {
+		return fmt.Errorf("no container %d", id)
+	}
+	for _, cmd := range strings.Split(cmds, ", ") {
+		node, parent := s.root.find(id)
+		var x, y int
+		switch {
+		case cmd == "move container to workspace number 99":
+			return errors.New("No workspace 99")
+		case strings.HasPrefix(cmd, "move container to workspace number "):
+			num, _ := strconv.Atoi(strings.TrimPrefix(cmd, "move container to workspace number "))
+			s.moveTo(id, func(ws *i3Node) bool { return ws.Num == num }, i3Node{Name: strconv.Itoa(num), Num: num})
+		case strings.HasPrefix(cmd, "move container to workspace "):
+			name, err := strconv.Unquote(strings.TrimPrefix(cmd, "move container to workspace "))
+			if err != nil {
+				return err
+			}
+			s.moveTo(id, func(ws *i3Node) bool { return ws.Name == name }, i3Node{Name: name, Num: -1})
+		case cmd == "move scratchpad":
+			node.Type = "floating_con"
+			s.moveTo(id, func(ws *i3Node) bool { return ws.Name == i3Scratchpad }, i3Node{})
+		case cmd == "scratchpad show":
+			if ws := s.root.workspace(id); ws == nil || ws.Name != i3Scratchpad {
+				// i3 hides a scratchpad window shown
+				s.moveTo(id, func(ws *i3Node) bool { return ws.Name == i3Scratchpad }, i3Node{})
+			} else {
+				s.moveTo(id, func(ws *i3Node) bool { return ws.Num == 1 }, i3Node{})
+			}
+		case cmd == "floating enable" || cmd == "floating disable":
+			ws := s.root.workspace(id)
+			moved := s.detach(id)
+			if cmd == "floating enable" {
+				moved.Type, moved.Floating = "floating_con", "user_on"
+				ws.FloatingNodes = append(ws.FloatingNodes, moved)
+			} else {
+				moved.Type, moved.Floating = "con", "user_off"
+				ws.Nodes = append(ws.Nodes, moved)
+			}
+		case cmd == "fullscreen enable":
+			node.FullscreenMode = 1
+		case cmd == "fullscreen disable":
+			node.FullscreenMode = 0
+		case strings.HasPrefix(cmd, "layout "):
+			parent.Layout = strings.TrimPrefix(cmd, "layout ")
+		case cmd == "focus":
+		default:
+			if _, err := fmt.Sscanf(cmd, "move absolute position %d px %d px", &x, &y); err == nil {
+				node.Rect.X, node.Rect.Y = x, y
+			} else if _, err := fmt.Sscanf(cmd, "resize set %d px %d px", &x, &y); err == nil {
+				node.Rect.Width, node.Rect.Height = x, y
+			} else {
+				return fmt.Errorf("unknown command %q", cmd)
+			}
+		}
+	}
+	return nil
+}
+
+func TestI3SwayTree(t *testing.T) {
+	_, b := newFakeI3Server(t, "sway_tree.json")
+	if err := b.PreCheck(); err != nil {
+		t.Fatal(err)
+	}
+	windows, err := b.QueryWindows(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []WindowInfo{
+		{App: "pavucontrol", Title: "Volume Control", Frame: Rect{X: 660, Y: 290, W: 600, H: 500}, WindowID: 11, Pid: 2210, State: &WindowState{Floating: true, Minimized: true}},
+		{App: "foot", Title: "~/src/api", Frame: Rect{W: 1280, H: 1440}, SpaceID: 1, DisplayID: 1, WindowID: 5, Pid: 1830, State: &WindowState{Layout: "splith"}},
+		{App: "firefox", Title: "Pull requests · Mozilla Firefox", Frame: Rect{X: 1280, Y: 26, W: 1280, H: 1414}, SpaceID: 1, DisplayID: 1, WindowID: 7, Pid: 1902, State: &WindowState{Layout: "tabbed"}},
+		{App: "Slack", Title: "general - Slack", Frame: Rect{X: 1280, Y: 26, W: 1280, H: 1414}, SpaceID: 1, DisplayID: 1, WindowID: 8, Pid: 2044, State: &WindowState{Layout: "tabbed"}},
+		{App: "org.gnome.Calculator", Title: "Calculator", Frame: Rect{X: 980, Y: 470, W: 600, H: 500}, SpaceID: 1, DisplayID: 1, WindowID: 9, Pid: 2301, State: &WindowState{Floating: true}},
+		{App: "thunderbird", Title: "Inbox - Thunderbird", Frame: Rect{X: 2560, W: 1920, H: 1080}, SpaceID: 3, DisplayID: 2, WindowID: 13, Pid: 2400, State: &WindowState{Fullscreen: true, Layout: "splitv"}},
+		{App: "obsidian", Title: "Daily - Obsidian", Frame: Rect{X: 2560, W: 1920, H: 1080}, SpaceName: "notes", DisplayID: 2, WindowID: 15, Pid: 2500, State: &WindowState{Layout: "splith"}},
+	}
+	if !reflect.DeepEqual(windows, want) {
+		got, _ := json.Marshal(windows)
+		t.Fatalf("windows:\n%s", got)
+	}
+	if displays, err := b.Displays(context.Background()); err != nil || !reflect.DeepEqual(displays, []int{1, 2}) {
+		t.Fatalf("displays: %v %v", displays, err)
+	}
+}
+
+func TestI3Tree(t *testing.T) {
+	_, b := newFakeI3Server(t, "i3_tree.json")
+	windows, err := b.QueryWindows(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []WindowInfo{
+		{App: "jetbrains-goland", Title: "main.go - GoLand", Frame: Rect{W: 1920, H: 540}, SpaceID: 2, DisplayID: 1, WindowID: 94558208535504, State: &WindowState{Layout: "splitv"}},
+		{App: "KeePassXC", Title: "KeePassXC", Frame: Rect{X: 562, Y: 260, W: 796, H: 578}, SpaceID: 2, DisplayID: 1, WindowID: 94558208541920, State: &WindowState{Floating: true}},
+	}
+	if !reflect.DeepEqual(windows, want) {
+		got, _ := json.Marshal(windows)
+		t.Fatalf("windows:\n%s", got)
+	}
+}
+
+func TestI3Restore(t *testing.T) {
+	s, b := newFakeI3Server(t, "sway_tree.json")
+	m := NewManager(b)
+	ctx := context.Background()
+	if err := m.TakeSnapshot(ctx); err != nil {
+		t.Fatal(err)
+	}
+	saved := []WindowInfo{
+		// back from the scratchpad, floating on workspace 2
+		{App: "pavucontrol", Frame: Rect{X: 10, Y: 20, W: 300, H: 200}, SpaceID: 2, State: &WindowState{Floating: true}},
+		// tiled in a stack on workspace 4
+		{App: "foot", Frame: Rect{W: 1280, H: 1440}, SpaceID: 4, State: &WindowState{Layout: "stacked"}},
+		{App: "thunderbird", SpaceID: 3, State: &WindowState{Layout: "splitv"}},
+		// on the named workspace notes
+		{App: "Slack", SpaceName: "notes", State: &WindowState{Layout: "splith"}},
+		{App: "org.gnome.Calculator", State: &WindowState{Floating: true, Minimized: true}},
+	}
+	for i := range saved {
+		if err := m.RestoreWindow(ctx, &saved[i], nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{
+		"[con_id=11] move container to workspace number 2",
+		"[con_id=11] move absolute position 10 px 20 px, resize set 300 px 200 px",
+		"[con_id=5] move container to workspace number 4",
+		"[con_id=5] layout stacked",
+		"[con_id=13] move container to workspace number 3",
+		"[con_id=13] fullscreen disable",
+		`[con_id=8] move container to workspace "notes"`,
+		"[con_id=9] move scratchpad",
+	}
+	if got := s.ran(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("commands:\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	windows, err := b.QueryWindows(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := make(map[string]WindowInfo)
+	for _, win := range windows {
+		restored[win.App] = win
+	}
+	for _, win := range saved {
+		got := restored[win.App]
+		if got.SpaceID != win.SpaceID || got.SpaceName != win.SpaceName || *got.State != *win.State ||
+			(win.State.Floating && !win.State.Minimized && got.Frame != win.Frame) {
+			t.Fatalf("%s restored as %+v %+v", win.App, got, got.State)
+		}
+	}
+
+	if err := b.MoveToSpace(ctx, 5, 99); err == nil || !strings.Contains(err.Error(), "No workspace 99") {
+		t.Fatalf("expect the error of i3, got %v", err)
+	}
+	if err := b.MoveToSpace(ctx, 5, 0); err == nil {
+		t.Fatal("expect an error for a window without workspace number")
+	}
+	if got := i3Quote(`a "b" \c`); got != `"a \"b\" \\c"` {
+		t.Fatalf("quote: %s", got)
+	}
+}
+
+func TestI3Message(t *testing.T) {
+	msg := encodeI3Message(i3RunCommand, "focus")
+	want := append([]byte("i3-ipc\x05\x00\x00\x00\x00\x00\x00\x00"), "focus"...)
+	if !reflect.DeepEqual(msg, want) {
+		t.Fatalf("message: %q, want %q", msg, want)
+	}
+	typ, payload, err := readI3Message(strings.NewReader(string(msg)))
+	if err != nil || typ != i3RunCommand || string(payload) != "focus" {
+		t.Fatalf("read: %d %q %v", typ, payload, err)
+	}
+	if _, _, err := readI3Message(strings.NewReader("i4-ipc\x00\x00\x00\x00\x00\x00\x00\x00")); err == nil {
+		t.Fatal("expect an error without the magic")
+	}
+}
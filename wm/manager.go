@@ -0,0 +1,215 @@
+package wm
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"projsnap/utils"
+	"slices"
+	"sync"
+)
+
+// Manager matches the windows of a snapshot to the live windows of its
+// backend and moves them back in place. The backend is checked the first time
+// it is used, commands not touching windows do not need it.
+type Manager struct {
+	backend      Backend
+	savedWindows []WindowInfo
+	readedWindow []int
+
+	checkOnce sync.Once
+	checkErr  error
+}
+
+func NewManager(backend Backend) *Manager {
+	return &Manager{
+		backend:      backend,
+		savedWindows: make([]WindowInfo, 0),
+	}
+}
+
+func (m *Manager) Backend() Backend {
+	return m.backend
+}
+
+// PreCheck tells why the backend can not be used, it runs the check once.
+func (m *Manager) PreCheck() error {
+	m.checkOnce.Do(func() {
+		m.checkErr = m.backend.PreCheck()
+	})
+	return m.checkErr
+}
+
+func (m *Manager) TakeSnapshot(ctx context.Context) error {
+	if err := m.PreCheck(); err != nil {
+		return err
+	}
+	windows, err := m.backend.QueryWindows(ctx)
+	if err != nil {
+		return err
+	}
+	m.savedWindows = windows
+	m.readedWindow = make([]int, len(windows))
+	return nil
+}
+
+// ParkWindows moves every window of appName to space. It queries the backend
+// itself and leaves the last TakeSnapshot alone, the windows of other apps may
+// be restored meanwhile.
+func (m *Manager) ParkWindows(ctx context.Context, appName string, space int) error {
+	if err := m.PreCheck(); err != nil {
+		return err
+	}
+	windows, err := m.backend.QueryWindows(ctx)
+	if err != nil {
+		return err
+	}
+	for _, win := range windows {
+		if win.App != appName {
+			continue
+		}
+		if err := m.backend.MoveToSpace(ctx, win.WindowID, space); err != nil {
+			return fmt.Errorf("move window %d to space %d fail: %v", win.WindowID, space, err)
+		}
+	}
+	return nil
+}
+
+// GetWindowInfo returns the next window of appName, or of one of pids when
+// the backend names the app differently (e.g. localized). Without pids they
+// are looked up by name.
+func (m *Manager) GetWindowInfo(ctx context.Context, appName string, pids []int) (*WindowInfo, error) {
+	win, err := m.GetWindowFromName(appName)
+	if err == nil {
+		return win, nil
+	}
+	// no english app name
+	if len(pids) == 0 {
+		if pids, err = utils.CurrentPlatform().PIDs(ctx, appName); err != nil {
+			return nil, err
+		}
+	}
+	if win, err := m.getWindowFromPIDs(pids); err == nil {
+		return win, nil
+	}
+	return nil, fmt.Errorf("window not found for app: %s", appName)
+}
+
+func (m *Manager) GetWindowFromName(appName string) (*WindowInfo, error) {
+	for i := len(m.savedWindows) - 1; i >= 0; i-- {
+		win := m.savedWindows[i]
+		if win.App == appName && m.readedWindow[i] == 0 {
+			m.readedWindow[i] = 1
+			return &win, nil
+		}
+	}
+	return nil, fmt.Errorf("window not found for app name: %s", appName)
+}
+
+func (m *Manager) getWindowFromPIDs(pids []int) (*WindowInfo, error) {
+	for i := len(m.savedWindows) - 1; i >= 0; i-- {
+		win := m.savedWindows[i]
+		if slices.Contains(pids, win.Pid) && m.readedWindow[i] == 0 {
+			m.readedWindow[i] = 1
+			return &win, nil
+		}
+	}
+	return nil, fmt.Errorf("window not found for pids: %v", pids)
+}
+
+func indexSet(indexes []int, err error) (map[int]bool, error) {
+	if err != nil || len(indexes) == 0 {
+		return nil, err
+	}
+	set := make(map[int]bool)
+	for _, index := range indexes {
+		set[index] = true
+	}
+	return set, nil
+}
+
+// Displays returns the index of every display the backend knows, nil when it
+// knows none.
+func (m *Manager) Displays(ctx context.Context) (map[int]bool, error) {
+	if err := m.PreCheck(); err != nil {
+		return nil, err
+	}
+	return indexSet(m.backend.Displays(ctx))
+}
+
+// Spaces returns the index of every space the backend knows, nil when it
+// knows none.
+func (m *Manager) Spaces(ctx context.Context) (map[int]bool, error) {
+	if err := m.PreCheck(); err != nil {
+		return nil, err
+	}
+	return indexSet(m.backend.Spaces(ctx))
+}
+
+// WindowsReady reports whether the last TakeSnapshot holds count windows of
+// appName or pids, or every window titled as in titles.
+func (m *Manager) WindowsReady(appName string, pids []int, count int, titles []string) bool {
+	n, found := 0, make(map[string]bool)
+	for _, win := range m.savedWindows {
+		if win.App == appName || slices.Contains(pids, win.Pid) {
+			n++
+			found[win.Title] = true
+		}
+	}
+	if n >= count {
+		return true
+	}
+	if len(titles) == 0 {
+		return false
+	}
+	for _, title := range titles {
+		if !found[title] {
+			return false
+		}
+	}
+	return true
+}
+
+// RestoreWindow moves the next window of win.App back in place, falling back
+// to the windows of pids when the app is named differently on this machine.
+// The state is restored before the frame, a floating window only takes its
+// frame once it floats.
+func (m *Manager) RestoreWindow(ctx context.Context, win *WindowInfo, pids []int) error {
+	// ignore
+	if win == nil {
+		return nil
+	}
+	curWin, err := m.GetWindowFromName(win.App)
+	if err != nil && len(pids) > 0 {
+		curWin, err = m.getWindowFromPIDs(pids)
+	}
+	if err != nil {
+		return err
+	}
+	curWinID := curWin.WindowID
+	log.Printf("curWinID:%d, win: %v\n", curWinID, win)
+
+	// 尝试移动到 space
+	if mover, ok := m.backend.(NamedSpaceMover); ok && win.SpaceID <= 0 && win.SpaceName != "" {
+		if err := mover.MoveToNamedSpace(ctx, curWinID, win.SpaceName); err != nil {
+			log.Printf("move to space %s fail: %v\n", win.SpaceName, err)
+		}
+	} else if err := m.backend.MoveToSpace(ctx, curWinID, win.SpaceID); err != nil {
+		log.Printf("move to space %d fail: %v\n", win.SpaceID, err)
+	}
+
+	if win.State != nil {
+		if err := m.backend.SetState(ctx, curWinID, *win.State); err != nil {
+			log.Printf("set state %+v failed for window %d: %v\n", *win.State, win.WindowID, err)
+		}
+		if win.State.Fullscreen || win.State.Maximized || win.State.Minimized {
+			return nil
+		}
+	}
+
+	// 恢复位置大小
+	if err := m.backend.SetFrame(ctx, curWinID, win.Frame); err != nil {
+		log.Printf("set frame %v failed for window %d: %v\n", win.Frame, win.WindowID, err)
+	}
+	return nil
+}
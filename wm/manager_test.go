@@ -0,0 +1,102 @@
+package wm
+
+import (
+	"context"
+	"projsnap/utils"
+	"testing"
+)
+
+func TestWindowsReady(t *testing.T) {
+	m := NewManager(None{})
+	m.savedWindows = []WindowInfo{
+		{App: "GoLand", Title: "api – main.go"},
+		{App: "Finder", Title: "work"},
+	}
+	if !m.WindowsReady("GoLand", nil, 1, nil) {
+		t.Fatal("one GoLand window is up")
+	}
+	if m.WindowsReady("GoLand", nil, 2, nil) {
+		t.Fatal("only one of two GoLand windows is up")
+	}
+	if !m.WindowsReady("GoLand", nil, 2, []string{"api – main.go"}) {
+		t.Fatal("every expected title is up")
+	}
+	if m.WindowsReady("Slack", nil, 1, []string{"general"}) {
+		t.Fatal("Slack has no window")
+	}
+}
+
+func TestWindowByPID(t *testing.T) {
+	m := NewManager(None{})
+	m.savedWindows = []WindowInfo{{App: "访达", Pid: 412, WindowID: 7}}
+	m.readedWindow = make([]int, 1)
+	if !m.WindowsReady("Finder", []int{412}, 1, nil) {
+		t.Fatal("the window of a pid counts for the app")
+	}
+	win, err := m.GetWindowInfo(context.Background(), "Finder", []int{412})
+	if err != nil || win.WindowID != 7 {
+		t.Fatalf("expect window 7 by pid, got %v %v", win, err)
+	}
+
+	// without pids they come from the platform
+	desktop := utils.NewFakeDesktop()
+	desktop.Install(utils.FakeApp{Name: "Finder"})
+	if err := desktop.Start("Finder", "work"); err != nil {
+		t.Fatal(err)
+	}
+	prev := utils.SetPlatform(desktop)
+	defer utils.SetPlatform(prev)
+	pids, _ := desktop.PIDs(context.Background(), "Finder")
+	m.savedWindows = append(m.savedWindows, WindowInfo{App: "访达", Pid: pids[0], WindowID: 8})
+	m.readedWindow = append(m.readedWindow, 0)
+	win, err = m.GetWindowInfo(context.Background(), "Finder", nil)
+	if err != nil || win.WindowID != 8 {
+		t.Fatalf("expect window 8 by the pid of the platform, got %v %v", win, err)
+	}
+}
+
+func TestRestoreWindow(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake(3)
+	fake.Add(WindowInfo{App: "Slack", Title: "general", WindowID: 4})
+	fake.Add(WindowInfo{App: "Notes", Title: "todo", WindowID: 5})
+	m := NewManager(fake)
+	if err := m.TakeSnapshot(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	frame := Rect{X: 10, Y: 20, W: 300, H: 400}
+	if err := m.RestoreWindow(ctx, &WindowInfo{App: "Slack", SpaceID: 2, Frame: frame, State: &WindowState{Floating: true}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if win, _ := fake.Window(4); win.SpaceID != 2 || win.Frame != frame || win.State == nil || !win.State.Floating {
+		t.Fatalf("slack not restored: %+v", win)
+	}
+	// a fullscreen window keeps the frame the window manager gives it
+	if err := m.RestoreWindow(ctx, &WindowInfo{App: "Notes", SpaceID: 3, Frame: frame, State: &WindowState{Fullscreen: true}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if win, _ := fake.Window(5); win.SpaceID != 3 || win.Frame != (Rect{}) || !win.State.Fullscreen {
+		t.Fatalf("notes not restored: %+v", win)
+	}
+	if err := m.RestoreWindow(ctx, &WindowInfo{App: "Mail"}, nil); err == nil {
+		t.Fatal("Mail has no window")
+	}
+	if spaces, _ := m.Spaces(ctx); len(spaces) != 3 {
+		t.Fatalf("spaces: %v", spaces)
+	}
+	if displays, _ := NewManager(None{}).Displays(ctx); displays != nil {
+		t.Fatalf("none knows no display: %v", displays)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, name := range []string{BackendNone, BackendI3, BackendHyprland, BackendX11} {
+		if backend, err := New(name); err != nil || backend.Name() != name {
+			t.Fatalf("%s: %v %v", name, backend, err)
+		}
+	}
+	if _, err := New("kwin"); err == nil {
+		t.Fatal("expect an error for an unknown window manager")
+	}
+}
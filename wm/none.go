@@ -0,0 +1,25 @@
+package wm
+
+import "context"
+
+// None manages no window, apps are restored and their windows are left where
+// the apps open them.
+type None struct{}
+
+func (None) Name() string { return BackendNone }
+
+func (None) PreCheck() error { return nil }
+
+func (None) QueryWindows(context.Context) ([]WindowInfo, error) { return nil, nil }
+
+func (None) Displays(context.Context) ([]int, error) { return nil, nil }
+
+func (None) Spaces(context.Context) ([]int, error) { return nil, nil }
+
+func (None) MoveToSpace(context.Context, int, int) error { return nil }
+
+func (None) SetFrame(context.Context, int, Rect) error { return nil }
+
+func (None) Focus(context.Context, int) error { return nil }
+
+func (None) SetState(context.Context, int, WindowState) error { return nil }
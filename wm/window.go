@@ -0,0 +1,102 @@
+// Package wm restores windows through a window manager backend: yabai, i3 or
+// sway, Hyprland, X11, none for platforms without one, or an in-memory fake
+// for tests.
+package wm
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+type Rect struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	W float64 `json:"w"`
+	H float64 `json:"h"`
+}
+
+// WindowState is how a window is shown, besides its frame.
+type WindowState struct {
+	Floating   bool `json:"floating,omitempty"`
+	Fullscreen bool `json:"fullscreen,omitempty"`
+	Maximized  bool `json:"maximized,omitempty"`
+	Minimized  bool `json:"minimized,omitempty"`
+	// Layout is the layout of the container directly holding a tiled window
+	// on a tiling window manager, e.g. splith or tabbed.
+	Layout string `json:"layout,omitempty"`
+}
+
+type WindowInfo struct {
+	App       string       `json:"app"`
+	Title     string       `json:"title"`
+	Frame     Rect         `json:"frame"`
+	SpaceID   int          `json:"space"`
+	DisplayID int          `json:"display"`
+	WindowID  int          `json:"id"`
+	Pid       int          `json:"pid"`
+	State     *WindowState `json:"state,omitempty"`
+	// SpaceName names a space that has no index, e.g. a named workspace of
+	// i3.
+	SpaceName string `json:"space_name,omitempty"`
+}
+
+// Backend is a window manager. Windows are addressed by the WindowID it
+// reported, spaces and displays by their index.
+type Backend interface {
+	Name() string
+	// PreCheck tells why the backend can not be used, e.g. it is not running.
+	PreCheck() error
+	QueryWindows(ctx context.Context) ([]WindowInfo, error)
+	// Displays and Spaces return the indexes known, nil if the backend has
+	// no such notion.
+	Displays(ctx context.Context) ([]int, error)
+	Spaces(ctx context.Context) ([]int, error)
+	MoveToSpace(ctx context.Context, id int, space int) error
+	SetFrame(ctx context.Context, id int, frame Rect) error
+	Focus(ctx context.Context, id int) error
+	SetState(ctx context.Context, id int, state WindowState) error
+}
+
+// NamedSpaceMover is implemented by backends whose spaces may have a name but
+// no index, the windows saved on one are moved back by its name.
+type NamedSpaceMover interface {
+	MoveToNamedSpace(ctx context.Context, id int, name string) error
+}
+
+const (
+	BackendYabai    = "yabai"
+	BackendI3       = "i3"
+	BackendHyprland = "hyprland"
+	BackendX11      = "x11"
+	BackendNone     = "none"
+)
+
+// DefaultBackend is yabai on macOS, elsewhere windows are not managed.
+func DefaultBackend() string {
+	if runtime.GOOS == "darwin" {
+		return BackendYabai
+	}
+	return BackendNone
+}
+
+// New returns the backend called name, "" is the DefaultBackend.
+func New(name string) (Backend, error) {
+	if name == "" {
+		name = DefaultBackend()
+	}
+	switch name {
+	case BackendYabai:
+		return NewYabai(), nil
+	case BackendI3:
+		return NewI3(), nil
+	case BackendHyprland:
+		return NewHyprland(), nil
+	case BackendX11:
+		return X11{}, nil
+	case BackendNone:
+		return None{}, nil
+	}
+	return nil, fmt.Errorf("unknown window manager %q, want %s", name, strings.Join([]string{BackendYabai, BackendI3, BackendHyprland, BackendX11, BackendNone}, ", "))
+}
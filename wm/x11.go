@@ -0,0 +1,322 @@
+package wm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// x11AllDesktops is the _NET_WM_DESKTOP of a window shown on every desktop.
+const x11AllDesktops = 0xFFFFFFFF
+
+const defaultX11Timeout = 5 * time.Second
+
+// X11 drives any EWMH window manager of X11 through xprop, xwininfo and
+// wmctrl. Spaces are the desktops counted from 1, X11 has no notion of
+// displays.
+type X11 struct{}
+
+func (X11) Name() string { return BackendX11 }
+
+func (X11) PreCheck() error {
+	for _, tool := range []string{"xprop", "xwininfo", "wmctrl"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			return fmt.Errorf("%s not found in PATH, need to install x11-utils and wmctrl", tool)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultX11Timeout)
+	defer cancel()
+	out, err := x11Command(ctx, "xprop", "-root", "_NET_SUPPORTING_WM_CHECK")
+	if err != nil {
+		return err
+	}
+	if _, ok := parseXprop(out)["_NET_SUPPORTING_WM_CHECK"]; !ok {
+		return errors.New("no EWMH window manager is running on $DISPLAY")
+	}
+	return nil
+}
+
+func x11Command(ctx context.Context, name string, args ...string) (string, error) {
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return "", fmt.Errorf("%s %s failed: %s", name, strings.Join(args, " "), strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %w", name, err)
+	}
+	return string(out), nil
+}
+
+// wmctrl runs wmctrl on window id, e.g. -t to send _NET_WM_DESKTOP.
+func wmctrl(ctx context.Context, id int, args ...string) error {
+	_, err := x11Command(ctx, "wmctrl", append([]string{"-i", "-r", x11ID(id)}, args...)...)
+	return err
+}
+
+func x11ID(id int) string {
+	return fmt.Sprintf("0x%x", id)
+}
+
+// parseXprop maps the properties in the output of xprop to their value, e.g.
+// `"xterm", "XTerm"` for WM_CLASS. The properties not set are left out.
+func parseXprop(out string) map[string]string {
+	props := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		name, rest, ok := strings.Cut(line, "(")
+		if !ok || name == "" || strings.ContainsAny(name, " \t:") {
+			continue
+		}
+		_, rest, ok = strings.Cut(rest, ")")
+		if !ok {
+			continue
+		}
+		rest = strings.TrimSpace(rest)
+		switch {
+		case strings.HasPrefix(rest, "="):
+			props[name] = strings.TrimSpace(rest[1:])
+		case strings.HasPrefix(rest, ":"):
+			// WINDOW: "window id # 0x1400006, 0x1600006"
+			if _, ids, ok := strings.Cut(rest, "#"); ok {
+				props[name] = strings.TrimSpace(ids)
+			}
+		}
+	}
+	return props
+}
+
+// xpropList splits a list of atoms, numbers or windows.
+func xpropList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// xpropStrings unquotes a list of strings as xprop escapes them.
+func xpropStrings(value string) []string {
+	strs := make([]string, 0)
+	for {
+		start := strings.IndexByte(value, '"')
+		if start < 0 {
+			return strs
+		}
+		end := start + 1
+		for end < len(value) && value[end] != '"' {
+			if value[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(value) {
+			return strs
+		}
+		if s, err := strconv.Unquote(value[start : end+1]); err == nil {
+			strs = append(strs, s)
+		} else {
+			strs = append(strs, value[start+1:end])
+		}
+		value = value[end+1:]
+	}
+}
+
+// parseXwininfo reads the position and size of the client area from the
+// output of xwininfo.
+func parseXwininfo(out string) (Rect, error) {
+	var frame Rect
+	found := 0
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		var field *float64
+		switch key {
+		case "Absolute upper-left X":
+			field = &frame.X
+		case "Absolute upper-left Y":
+			field = &frame.Y
+		case "Width":
+			field = &frame.W
+		case "Height":
+			field = &frame.H
+		default:
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return Rect{}, fmt.Errorf("unexpected xwininfo %s: %q", key, value)
+		}
+		*field = float64(n)
+		found++
+	}
+	if found < 4 {
+		return Rect{}, errors.New("geometry missing in the output of xwininfo")
+	}
+	return frame, nil
+}
+
+// x11State reads the state of a window from _NET_WM_STATE.
+func x11State(props map[string]string) *WindowState {
+	atoms := xpropList(props["_NET_WM_STATE"])
+	return &WindowState{
+		Fullscreen: slices.Contains(atoms, "_NET_WM_STATE_FULLSCREEN"),
+		Maximized:  slices.Contains(atoms, "_NET_WM_STATE_MAXIMIZED_VERT") && slices.Contains(atoms, "_NET_WM_STATE_MAXIMIZED_HORZ"),
+		Minimized:  slices.Contains(atoms, "_NET_WM_STATE_HIDDEN"),
+	}
+}
+
+// x11Window fills a WindowInfo from the properties of window id and the
+// geometry of its client area. The frame is the one of the decorations
+// around it, the one _NET_MOVERESIZE_WINDOW places.
+func x11Window(id int, props map[string]string, client Rect) WindowInfo {
+	info := WindowInfo{WindowID: id, Frame: client, State: x11State(props)}
+	if class := xpropStrings(props["WM_CLASS"]); len(class) == 2 {
+		info.App = class[1]
+	}
+	if title := xpropStrings(props["_NET_WM_NAME"]); len(title) > 0 {
+		info.Title = title[0]
+	} else if title := xpropStrings(props["WM_NAME"]); len(title) > 0 {
+		info.Title = title[0]
+	}
+	info.Pid, _ = strconv.Atoi(props["_NET_WM_PID"])
+	if desktop, err := strconv.ParseUint(props["_NET_WM_DESKTOP"], 10, 32); err == nil && desktop != x11AllDesktops {
+		info.SpaceID = int(desktop) + 1
+	}
+	// left, right, top, bottom
+	if extents := xpropList(props["_NET_FRAME_EXTENTS"]); len(extents) == 4 {
+		left, _ := strconv.Atoi(extents[0])
+		top, _ := strconv.Atoi(extents[2])
+		info.Frame.X -= float64(left)
+		info.Frame.Y -= float64(top)
+	}
+	return info
+}
+
+func (x X11) window(ctx context.Context, id int) (WindowInfo, error) {
+	out, err := x11Command(ctx, "xprop", "-id", x11ID(id),
+		"_NET_WM_PID", "WM_CLASS", "_NET_WM_DESKTOP", "_NET_WM_NAME", "WM_NAME", "_NET_WM_STATE", "_NET_FRAME_EXTENTS")
+	if err != nil {
+		return WindowInfo{}, err
+	}
+	geometry, err := x11Command(ctx, "xwininfo", "-id", x11ID(id))
+	if err != nil {
+		return WindowInfo{}, err
+	}
+	client, err := parseXwininfo(geometry)
+	if err != nil {
+		return WindowInfo{}, err
+	}
+	return x11Window(id, parseXprop(out), client), nil
+}
+
+func (x X11) root(ctx context.Context) (map[string]string, error) {
+	out, err := x11Command(ctx, "xprop", "-root", "_NET_CLIENT_LIST", "_NET_NUMBER_OF_DESKTOPS")
+	if err != nil {
+		return nil, err
+	}
+	return parseXprop(out), nil
+}
+
+// QueryWindows lists the windows of _NET_CLIENT_LIST, the ones closed while
+// they are read are left out.
+func (x X11) QueryWindows(ctx context.Context) ([]WindowInfo, error) {
+	root, err := x.root(ctx)
+	if err != nil {
+		return nil, err
+	}
+	windows := make([]WindowInfo, 0)
+	for _, item := range xpropList(root["_NET_CLIENT_LIST"]) {
+		id, err := strconv.ParseInt(item, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected window id %q", item)
+		}
+		win, err := x.window(ctx, int(id))
+		if err != nil {
+			continue
+		}
+		windows = append(windows, win)
+	}
+	return windows, nil
+}
+
+func (X11) Displays(context.Context) ([]int, error) {
+	return nil, nil
+}
+
+func (x X11) Spaces(ctx context.Context) ([]int, error) {
+	root, err := x.root(ctx)
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(root["_NET_NUMBER_OF_DESKTOPS"])
+	spaces := make([]int, 0, n)
+	for i := 1; i <= n; i++ {
+		spaces = append(spaces, i)
+	}
+	return spaces, nil
+}
+
+func (X11) MoveToSpace(ctx context.Context, id int, space int) error {
+	if space <= 0 {
+		return fmt.Errorf("window %s was on no desktop or all of them", x11ID(id))
+	}
+	return wmctrl(ctx, id, "-t", strconv.Itoa(space-1))
+}
+
+func (X11) SetFrame(ctx context.Context, id int, frame Rect) error {
+	return wmctrl(ctx, id, "-e", fmt.Sprintf("0,%d,%d,%d,%d", int(frame.X), int(frame.Y), int(frame.W), int(frame.H)))
+}
+
+// Focus sends _NET_ACTIVE_WINDOW, the window manager switches to its desktop.
+func (X11) Focus(ctx context.Context, id int) error {
+	_, err := x11Command(ctx, "wmctrl", "-i", "-a", x11ID(id))
+	return err
+}
+
+func x11Switch(on bool, props string) string {
+	if on {
+		return "add," + props
+	}
+	return "remove," + props
+}
+
+// SetState changes what differs from state through _NET_WM_STATE. A minimized
+// window is shown by activating it, and minimized by xdotool, EWMH leaves it
+// to the window manager.
+func (x X11) SetState(ctx context.Context, id int, state WindowState) error {
+	win, err := x.window(ctx, id)
+	if err != nil {
+		return err
+	}
+	cur := win.State
+	if cur.Minimized && !state.Minimized {
+		if err := x.Focus(ctx, id); err != nil {
+			return err
+		}
+	}
+	if cur.Fullscreen != state.Fullscreen {
+		if err := wmctrl(ctx, id, "-b", x11Switch(state.Fullscreen, "fullscreen")); err != nil {
+			return err
+		}
+	}
+	if cur.Maximized != state.Maximized {
+		if err := wmctrl(ctx, id, "-b", x11Switch(state.Maximized, "maximized_vert,maximized_horz")); err != nil {
+			return err
+		}
+	}
+	if !cur.Minimized && state.Minimized {
+		if _, err := x11Command(ctx, "xdotool", "windowminimize", strconv.Itoa(id)); err != nil {
+			return fmt.Errorf("minimize needs xdotool: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package wm
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readTestdata(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "x11", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestParseXprop(t *testing.T) {
+	root := parseXprop(readTestdata(t, "root.txt"))
+	if ids := xpropList(root["_NET_CLIENT_LIST"]); !reflect.DeepEqual(ids, []string{"0x1c00003", "0x2200007", "0x2600001"}) {
+		t.Fatalf("client list: %q", ids)
+	}
+	if root["_NET_NUMBER_OF_DESKTOPS"] != "4" {
+		t.Fatalf("desktops: %q", root["_NET_NUMBER_OF_DESKTOPS"])
+	}
+
+	client, err := parseXwininfo(readTestdata(t, "xwininfo.txt"))
+	if err != nil || client != (Rect{X: 2, Y: 28, W: 1276, H: 770}) {
+		t.Fatalf("geometry: %+v %v", client, err)
+	}
+	win := x11Window(0x2200007, parseXprop(readTestdata(t, "xprop.txt")), client)
+	want := WindowInfo{
+		App: "Gnome-terminal", Title: `vim "main.go" – ~/src/api`, Frame: Rect{W: 1276, H: 770},
+		SpaceID: 2, WindowID: 0x2200007, Pid: 4242, State: &WindowState{Maximized: true},
+	}
+	if !reflect.DeepEqual(win, want) {
+		t.Fatalf("window:\n%+v\nwant\n%+v", win, want)
+	}
+
+	sticky := x11Window(0x2600001, parseXprop(readTestdata(t, "xprop_sticky.txt")), Rect{X: 10, Y: 10, W: 100, H: 100})
+	want = WindowInfo{App: "XLogo", Title: "xlogo", Frame: Rect{X: 10, Y: 10, W: 100, H: 100}, WindowID: 0x2600001, State: &WindowState{Minimized: true}}
+	if !reflect.DeepEqual(sticky, want) {
+		t.Fatalf("a window on every desktop has no space:\n%+v", sticky)
+	}
+	if _, err := parseXwininfo("xwininfo: error: No such window with id 0x42.\n"); err == nil {
+		t.Fatal("expect an error without geometry")
+	}
+}
+
+// startXvfb starts Xvfb on a free display, a minimal window manager and
+// client on it, and points $DISPLAY at it.
+func startXvfb(t *testing.T) {
+	for _, tool := range []string{"Xvfb", "xprop", "xwininfo", "wmctrl"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not installed", tool)
+		}
+	}
+	pick := func(names ...string) string {
+		for _, name := range names {
+			if _, err := exec.LookPath(name); err == nil {
+				return name
+			}
+		}
+		return ""
+	}
+	manager, client := pick("openbox", "jwm", "fluxbox", "icewm"), pick("xlogo", "xeyes", "xterm")
+	if manager == "" || client == "" {
+		t.Skip("need an EWMH window manager and an X client, e.g. openbox and xlogo")
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	xvfb := exec.Command("Xvfb", "-displayfd", "3", "-screen", "0", "1280x800x24", "-nolisten", "tcp")
+	xvfb.ExtraFiles = []*os.File{w}
+	if err := xvfb.Start(); err != nil {
+		t.Skip(err)
+	}
+	_ = w.Close()
+	t.Cleanup(func() { _ = xvfb.Process.Kill(); _ = xvfb.Wait() })
+	display, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Xvfb display: %v", err)
+	}
+	t.Setenv("DISPLAY", ":"+strings.TrimSpace(display))
+
+	for _, name := range []string{manager, client} {
+		cmd := exec.Command(name)
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = cmd.Process.Kill(); _ = cmd.Wait() })
+		if name == manager {
+			waitFor(t, "window manager", func() bool { return X11{}.PreCheck() == nil })
+		}
+	}
+}
+
+func waitFor(t *testing.T, what string, ok func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(10 * time.Second); !ok(); time.Sleep(50 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+	}
+}
+
+func TestX11Xvfb(t *testing.T) {
+	startXvfb(t)
+	ctx := context.Background()
+	x := X11{}
+	if _, err := x11Command(ctx, "wmctrl", "-n", "4"); err != nil {
+		t.Fatal(err)
+	}
+	var win WindowInfo
+	waitFor(t, "client window", func() bool {
+		windows, err := x.QueryWindows(ctx)
+		if err != nil || len(windows) == 0 {
+			return false
+		}
+		win = windows[0]
+		return true
+	})
+	if spaces, err := x.Spaces(ctx); err != nil || len(spaces) != 4 {
+		t.Fatalf("spaces: %v %v", spaces, err)
+	}
+
+	if err := x.MoveToSpace(ctx, win.WindowID, 3); err != nil {
+		t.Fatal(err)
+	}
+	frame := Rect{X: 100, Y: 120, W: 300, H: 200}
+	if err := x.SetFrame(ctx, win.WindowID, frame); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "window on desktop 3", func() bool {
+		cur, err := x.window(ctx, win.WindowID)
+		return err == nil && cur.SpaceID == 3 && cur.Frame.W == frame.W && cur.Frame.H == frame.H
+	})
+
+	if err := x.SetState(ctx, win.WindowID, WindowState{Maximized: true}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "maximized window", func() bool {
+		cur, err := x.window(ctx, win.WindowID)
+		return err == nil && cur.State.Maximized
+	})
+	if err := x.SetState(ctx, win.WindowID, WindowState{}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "restored window", func() bool {
+		cur, err := x.window(ctx, win.WindowID)
+		return err == nil && !cur.State.Maximized
+	})
+}
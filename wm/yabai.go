@@ -0,0 +1,195 @@
+package wm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Yabai drives yabai over its socket, or through the `yabai -m` command when
+// the socket is not available.
+type Yabai struct {
+	client *YabaiClient
+}
+
+func NewYabai() *Yabai {
+	return &Yabai{client: NewYabaiClient()}
+}
+
+func execYabai(ctx context.Context, args ...string) ([]byte, error) {
+	output, err := exec.CommandContext(ctx, "yabai", append([]string{"-m"}, args...)...).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return output, &YabaiError{Args: args, Message: strings.TrimSpace(string(exitErr.Stderr))}
+	}
+	return output, err
+}
+
+func (y *Yabai) run(ctx context.Context, args ...string) ([]byte, error) {
+	outs, err := y.sendEach(ctx, args)
+	return outs[0], err
+}
+
+// sendEach sends msgs in order and keeps going past the refused ones.
+func (y *Yabai) sendEach(ctx context.Context, msgs ...[]string) ([][]byte, error) {
+	if y.client != nil {
+		outs, err := y.client.SendEach(ctx, msgs...)
+		if !errors.Is(err, ErrNoSocket) {
+			return outs, err
+		}
+	}
+	outs := make([][]byte, len(msgs))
+	errs := make([]error, 0)
+	for i, args := range msgs {
+		var err error
+		if outs[i], err = execYabai(ctx, args...); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return outs, errors.Join(errs...)
+}
+
+func (y *Yabai) Name() string { return BackendYabai }
+
+// PreCheck asks the socket first, the yabai command is only looked for when
+// the socket is not available.
+func (y *Yabai) PreCheck() error {
+	if y.client != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), y.client.Timeout)
+		defer cancel()
+		_, err := y.client.Send(ctx, "query", "--displays")
+		var yabaiErr *YabaiError
+		if err == nil || errors.As(err, &yabaiErr) {
+			return nil
+		}
+		if !errors.Is(err, ErrNoSocket) {
+			return fmt.Errorf("yabai is not responding: %w", err)
+		}
+	}
+	_, err := exec.LookPath("yabai")
+	if err != nil {
+		return errors.New("yabai not found in PATH, need to `brew install koekeishiya/formulae/yabai`")
+	}
+	if !IsYabaiRunning() {
+		return errors.New("yabai is not running, you should run `yabai --start-service`")
+	}
+	return nil
+}
+
+func IsYabaiRunning() bool {
+	cmd := exec.Command("pgrep", "yabai")
+	err := cmd.Run()
+	return err == nil
+}
+
+// yabaiWindow is a window as yabai reports it.
+type yabaiWindow struct {
+	WindowInfo
+	Floating   bool `json:"is-floating"`
+	Zoomed     bool `json:"has-fullscreen-zoom"`
+	Fullscreen bool `json:"is-native-fullscreen"`
+	Minimized  bool `json:"is-minimized"`
+}
+
+func (w *yabaiWindow) info() WindowInfo {
+	info := w.WindowInfo
+	info.State = &WindowState{Floating: w.Floating, Fullscreen: w.Zoomed || w.Fullscreen, Minimized: w.Minimized}
+	return info
+}
+
+func (y *Yabai) QueryWindows(ctx context.Context) ([]WindowInfo, error) {
+	output, err := y.run(ctx, "query", "--windows")
+	if err != nil {
+		return nil, fmt.Errorf("yabai query failed: %w", err)
+	}
+	var windows []yabaiWindow
+	if err := json.Unmarshal(output, &windows); err != nil {
+		return nil, fmt.Errorf("unmarshal failed: %w", err)
+	}
+	result := make([]WindowInfo, 0, len(windows))
+	for i := range windows {
+		result = append(result, windows[i].info())
+	}
+	return result, nil
+}
+
+type yabaiIndex struct {
+	Index int `json:"index"`
+}
+
+func (y *Yabai) indexes(ctx context.Context, domain string) ([]int, error) {
+	output, err := y.run(ctx, "query", domain)
+	if err != nil {
+		return nil, fmt.Errorf("yabai query failed: %w", err)
+	}
+	var items []yabaiIndex
+	if err := json.Unmarshal(output, &items); err != nil {
+		return nil, fmt.Errorf("unmarshal failed: %w", err)
+	}
+	indexes := make([]int, 0, len(items))
+	for _, item := range items {
+		indexes = append(indexes, item.Index)
+	}
+	return indexes, nil
+}
+
+func (y *Yabai) Displays(ctx context.Context) ([]int, error) {
+	return y.indexes(ctx, "--displays")
+}
+
+func (y *Yabai) Spaces(ctx context.Context) ([]int, error) {
+	return y.indexes(ctx, "--spaces")
+}
+
+func (y *Yabai) MoveToSpace(ctx context.Context, id int, space int) error {
+	_, err := y.run(ctx, "window", strconv.Itoa(id), "--space", strconv.Itoa(space))
+	return err
+}
+
+func (y *Yabai) SetFrame(ctx context.Context, id int, frame Rect) error {
+	wid := strconv.Itoa(id)
+	_, err := y.sendEach(ctx,
+		[]string{"window", wid, "--move", fmt.Sprintf("abs:%d:%d", int(frame.X), int(frame.Y))},
+		[]string{"window", wid, "--resize", fmt.Sprintf("abs:%d:%d", int(frame.W), int(frame.H))})
+	return err
+}
+
+func (y *Yabai) Focus(ctx context.Context, id int) error {
+	_, err := y.run(ctx, "window", "--focus", strconv.Itoa(id))
+	return err
+}
+
+// SetState toggles what differs from state, yabai only knows toggles.
+func (y *Yabai) SetState(ctx context.Context, id int, state WindowState) error {
+	output, err := y.run(ctx, "query", "--windows", "--window", strconv.Itoa(id))
+	if err != nil {
+		return fmt.Errorf("yabai query failed: %w", err)
+	}
+	var win yabaiWindow
+	if err := json.Unmarshal(output, &win); err != nil {
+		return fmt.Errorf("unmarshal failed: %w", err)
+	}
+	cur, wid := win.info().State, strconv.Itoa(id)
+	msgs := make([][]string, 0, 3)
+	if cur.Minimized && !state.Minimized {
+		msgs = append(msgs, []string{"window", "--deminimize", wid})
+	}
+	if cur.Floating != state.Floating {
+		msgs = append(msgs, []string{"window", wid, "--toggle", "float"})
+	}
+	if cur.Fullscreen != state.Fullscreen {
+		msgs = append(msgs, []string{"window", wid, "--toggle", "zoom-fullscreen"})
+	}
+	if !cur.Minimized && state.Minimized {
+		msgs = append(msgs, []string{"window", wid, "--minimize"})
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	_, err = y.sendEach(ctx, msgs...)
+	return err
+}
@@ -0,0 +1,106 @@
+package wm
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// failureMessage starts the response of a message yabai refused.
+const failureMessage = '\x07'
+
+const defaultYabaiTimeout = 5 * time.Second
+
+// ErrNoSocket is returned when yabai does not listen on its socket, e.g. it
+// is not running or too old.
+var ErrNoSocket = errors.New("yabai socket not available")
+
+// YabaiError is a message yabai refused, with the reason it gave.
+type YabaiError struct {
+	Args    []string
+	Message string
+}
+
+func (e *YabaiError) Error() string {
+	return fmt.Sprintf("yabai -m %s: %s", strings.Join(e.Args, " "), e.Message)
+}
+
+// YabaiClient speaks the protocol of `yabai -m` over the socket of yabai,
+// without a process per message.
+type YabaiClient struct {
+	Path    string
+	Timeout time.Duration
+}
+
+// NewYabaiClient returns a client of the socket yabai opens for $USER.
+func NewYabaiClient() *YabaiClient {
+	return &YabaiClient{Path: fmt.Sprintf("/tmp/yabai_%s.socket", os.Getenv("USER")), Timeout: defaultYabaiTimeout}
+}
+
+// encodeYabaiMessage frames args as yabai reads them: the length of the body
+// as a 32-bit little-endian int, then every arg and the body NUL-terminated.
+func encodeYabaiMessage(args []string) []byte {
+	n := 1
+	for _, arg := range args {
+		n += len(arg) + 1
+	}
+	msg := binary.LittleEndian.AppendUint32(make([]byte, 0, 4+n), uint32(n))
+	for _, arg := range args {
+		msg = append(msg, arg...)
+		msg = append(msg, 0)
+	}
+	return append(msg, 0)
+}
+
+// Send sends one message and returns the response of yabai.
+func (c *YabaiClient) Send(ctx context.Context, args ...string) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", c.Path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNoSocket, err)
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(c.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetDeadline(deadline)
+
+	if _, err := conn.Write(encodeYabaiMessage(args)); err != nil {
+		return nil, fmt.Errorf("send to yabai failed: %w", err)
+	}
+	out, err := io.ReadAll(conn)
+	if err != nil {
+		return nil, fmt.Errorf("read from yabai failed: %w", err)
+	}
+	if len(out) > 0 && out[0] == failureMessage {
+		return nil, &YabaiError{Args: args, Message: strings.TrimSpace(string(out[1:]))}
+	}
+	return out, nil
+}
+
+// SendEach sends msgs in order and keeps going past the refused ones, yabai
+// answers one message per connection so each has its own. It returns the
+// response of every message and the errors joined.
+func (c *YabaiClient) SendEach(ctx context.Context, msgs ...[]string) ([][]byte, error) {
+	outs := make([][]byte, len(msgs))
+	errs := make([]error, 0)
+	for i, args := range msgs {
+		out, err := c.Send(ctx, args...)
+		if errors.Is(err, ErrNoSocket) && i == 0 {
+			// nothing was sent, the caller may send it another way
+			return outs, err
+		}
+		outs[i] = out
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return outs, errors.Join(errs...)
+}
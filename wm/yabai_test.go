@@ -0,0 +1,202 @@
+package wm
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeYabaiServer replays the responses recorded in testdata/yabai for the
+// queries, and accepts the window commands in accept.
+type fakeYabaiServer struct {
+	ln     net.Listener
+	accept map[string]bool
+
+	mu       sync.Mutex
+	received []string
+}
+
+func newFakeYabaiServer(t *testing.T, accept ...string) (*fakeYabaiServer, string) {
+	// a socket path is limited to about 100 bytes, t.TempDir may be longer
+	dir, err := os.MkdirTemp("", "yabai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	path := filepath.Join(dir, "yabai.socket")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skip(err)
+	}
+	s := &fakeYabaiServer{ln: ln, accept: make(map[string]bool)}
+	for _, msg := range accept {
+		s.accept[msg] = true
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go s.serve(t)
+	return s, path
+}
+
+func (s *fakeYabaiServer) serve(t *testing.T) {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.handle(t, conn)
+	}
+}
+
+func (s *fakeYabaiServer) handle(t *testing.T, conn net.Conn) {
+	defer conn.Close()
+	var n uint32
+	if err := binary.Read(conn, binary.LittleEndian, &n); err != nil {
+		t.Error(err)
+		return
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(conn, body); err != nil {
+		t.Error(err)
+		return
+	}
+	if len(body) < 2 || body[len(body)-1] != 0 || body[len(body)-2] != 0 {
+		t.Errorf("message not terminated: %q", body)
+		return
+	}
+	msg := strings.ReplaceAll(string(body[:len(body)-2]), "\x00", " ")
+	s.mu.Lock()
+	s.received = append(s.received, msg)
+	s.mu.Unlock()
+
+	if strings.HasPrefix(msg, "query --") && !strings.Contains(msg, "--window ") {
+		data, err := os.ReadFile(filepath.Join("testdata", "yabai", "query_"+strings.TrimPrefix(msg, "query --")+".json"))
+		if err == nil {
+			_, _ = conn.Write(data)
+			return
+		}
+	}
+	if msg == "query --windows --window 5120" {
+		_, _ = conn.Write([]byte(`{"id":5120,"app":"Slack","is-floating":true,"is-minimized":true,"has-fullscreen-zoom":false}`))
+		return
+	}
+	if s.accept[msg] {
+		return
+	}
+	_, _ = conn.Write([]byte("\x07could not locate window with the specified id '" + strings.Fields(msg + " ?")[1] + "'.\n"))
+}
+
+func (s *fakeYabaiServer) messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.received
+}
+
+func newSocketYabai(path string) *Yabai {
+	return &Yabai{client: &YabaiClient{Path: path, Timeout: time.Second}}
+}
+
+func TestYabaiSocketQuery(t *testing.T) {
+	_, path := newFakeYabaiServer(t)
+	y := newSocketYabai(path)
+	ctx := context.Background()
+
+	windows, err := y.QueryWindows(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []WindowInfo{
+		{App: "GoLand", Title: "api – main.go", Frame: Rect{Y: 25, W: 1280, H: 775}, SpaceID: 2, DisplayID: 1, WindowID: 4711, Pid: 812, State: &WindowState{}},
+		{App: "Slack", Title: "general - Slack", Frame: Rect{X: 200, Y: 120, W: 900, H: 600}, SpaceID: 3, DisplayID: 1, WindowID: 5120, Pid: 903, State: &WindowState{Floating: true, Minimized: true}},
+	}
+	if !reflect.DeepEqual(windows, want) {
+		t.Fatalf("windows:\n%+v\nwant\n%+v", windows, want)
+	}
+	if spaces, err := y.Spaces(ctx); err != nil || !reflect.DeepEqual(spaces, []int{1, 2, 3}) {
+		t.Fatalf("spaces: %v %v", spaces, err)
+	}
+	if displays, err := y.Displays(ctx); err != nil || !reflect.DeepEqual(displays, []int{1}) {
+		t.Fatalf("displays: %v %v", displays, err)
+	}
+}
+
+func TestYabaiSocketCommands(t *testing.T) {
+	s, path := newFakeYabaiServer(t,
+		"window 5120 --move abs:10:20",
+		"window 5120 --resize abs:300:400",
+		"window --deminimize 5120",
+		"window 5120 --toggle zoom-fullscreen",
+	)
+	y := newSocketYabai(path)
+	ctx := context.Background()
+
+	if err := y.SetFrame(ctx, 5120, Rect{X: 10, Y: 20, W: 300, H: 400}); err != nil {
+		t.Fatal(err)
+	}
+	// floating stays, the rest is toggled
+	if err := y.SetState(ctx, 5120, WindowState{Floating: true, Fullscreen: true}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"window 5120 --move abs:10:20",
+		"window 5120 --resize abs:300:400",
+		"query --windows --window 5120",
+		"window --deminimize 5120",
+		"window 5120 --toggle zoom-fullscreen",
+	}
+	if got := s.messages(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("messages:\n%q\nwant\n%q", got, want)
+	}
+
+	err := y.SetFrame(ctx, 42, Rect{W: 1, H: 1})
+	var yabaiErr *YabaiError
+	if !errors.As(err, &yabaiErr) || yabaiErr.Args[1] != "42" || !strings.Contains(yabaiErr.Message, "could not locate window") {
+		t.Fatalf("expect a structured error, got %v", err)
+	}
+	if len(s.messages()) != len(want)+2 {
+		t.Fatal("the messages after a refused one are still sent")
+	}
+}
+
+func TestYabaiPreCheck(t *testing.T) {
+	// the yabai command is not installed here
+	t.Setenv("PATH", t.TempDir())
+	s, path := newFakeYabaiServer(t)
+	if err := newSocketYabai(path).PreCheck(); err != nil {
+		t.Fatalf("the socket answers, got %v", err)
+	}
+	if got := s.messages(); !reflect.DeepEqual(got, []string{"query --displays"}) {
+		t.Fatalf("messages: %q", got)
+	}
+	if err := newSocketYabai(filepath.Join(t.TempDir(), "missing.socket")).PreCheck(); err == nil || !strings.Contains(err.Error(), "not found in PATH") {
+		t.Fatalf("expect the yabai command to be looked for without the socket, got %v", err)
+	}
+}
+
+func TestYabaiFallback(t *testing.T) {
+	y := newSocketYabai(filepath.Join(t.TempDir(), "missing.socket"))
+	if _, err := y.client.Send(context.Background(), "query", "--windows"); !errors.Is(err, ErrNoSocket) {
+		t.Fatalf("expect ErrNoSocket, got %v", err)
+	}
+	// without the socket the yabai command is run, it is not installed here
+	t.Setenv("PATH", t.TempDir())
+	if _, err := y.QueryWindows(context.Background()); err == nil || errors.Is(err, ErrNoSocket) {
+		t.Fatalf("expect the error of the yabai command, got %v", err)
+	}
+}
+
+func TestEncodeYabaiMessage(t *testing.T) {
+	msg := encodeYabaiMessage([]string{"query", "--spaces"})
+	want := append([]byte{16, 0, 0, 0}, "query\x00--spaces\x00\x00"...)
+	if !reflect.DeepEqual(msg, want) {
+		t.Fatalf("message: %q, want %q", msg, want)
+	}
+}